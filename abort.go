@@ -0,0 +1,11 @@
+// +build go1.8
+
+package xlog
+
+import "net/http"
+
+// isAbortHandler returns true if v is the sentinel panic value used to abort a
+// request.
+func isAbortHandler(v interface{}) bool {
+	return v == http.ErrAbortHandler
+}
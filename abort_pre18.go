@@ -0,0 +1,9 @@
+// +build !go1.8
+
+package xlog
+
+// isAbortHandler returns true if v is the sentinel panic value used to abort a
+// request. There is no such value before Go 1.8.
+func isAbortHandler(v interface{}) bool {
+	return false
+}
@@ -0,0 +1,22 @@
+// +build go1.8
+
+package xlog
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecoverHandlerAbort(t *testing.T) {
+	o := newTestOutput()
+	h := RecoverHandler()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+	h = NewHandler(Config{Output: o})(h)
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		h.ServeHTTP(nil, &http.Request{})
+	})
+	assert.True(t, o.empty())
+}
@@ -0,0 +1,177 @@
+package xlog
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// AdminHandler is an http.Handler exposing the logging configuration of a running
+// service as JSON.
+//
+// A GET request returns the current state, with the statistics of the output
+// channels if any:
+//
+//	{"level": "info", "components": {"db": "warn"}, "outputs": {"main": {"queue_length": 0, …}}}
+//
+// A PUT request changes it. When revert_after is set, the levels are restored to
+// their previous value once the duration has elapsed:
+//
+//	{"level": "debug", "components": {"db": "debug"}, "revert_after": "5m"}
+//
+// The handler does not perform any authentication, make sure it is not exposed
+// publicly.
+type AdminHandler struct {
+	// Level is the level variable to view and change. It should be the one set
+	// as Config.Level on the loggers to control.
+	Level *LevelVar
+	// Components are the component level variables to view and change. They
+	// should be the ones set in Config.ComponentLevels on the loggers to control.
+	Components map[string]*LevelVar
+	// Outputs are the output channels whose statistics are reported, by name.
+	Outputs map[string]*OutputChannel
+
+	mu     sync.Mutex
+	revert *time.Timer
+	// revertState holds the levels to restore when revert fires
+	revertState adminState
+	revertAt    time.Time
+}
+
+type adminState struct {
+	Level      Level            `json:"level"`
+	Components map[string]Level `json:"components,omitempty"`
+	RevertAt   *time.Time       `json:"revert_at,omitempty"`
+
+	Outputs map[string]OutputChannelStats `json:"outputs,omitempty"`
+}
+
+type adminUpdate struct {
+	Level       *Level           `json:"level"`
+	Components  map[string]Level `json:"components"`
+	RevertAfter string           `json:"revert_after"`
+}
+
+// ServeHTTP implements http.Handler interface
+func (h *AdminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.Level == nil {
+		http.Error(w, "no level configured", http.StatusInternalServerError)
+		return
+	}
+	switch r.Method {
+	case "GET", "HEAD":
+	case "PUT":
+		if status, err := h.update(r); err != nil {
+			http.Error(w, err.Error(), status)
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, HEAD, PUT")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.state())
+}
+
+func (h *AdminHandler) update(r *http.Request) (int, error) {
+	u := adminUpdate{}
+	if err := json.NewDecoder(r.Body).Decode(&u); err != nil {
+		return http.StatusBadRequest, err
+	}
+	var revertAfter time.Duration
+	if u.RevertAfter != "" {
+		d, err := time.ParseDuration(u.RevertAfter)
+		if err != nil {
+			return http.StatusBadRequest, err
+		}
+		revertAfter = d
+	}
+	for c := range u.Components {
+		if h.Components[c] == nil {
+			return http.StatusBadRequest, fmt.Errorf("unknown component %q", c)
+		}
+	}
+	h.apply(u.Level, u.Components, revertAfter)
+	return http.StatusOK, nil
+}
+
+// apply changes the levels and schedules their revert if d is positive. If a
+// revert is already pending, the levels restored are the ones set before the
+// first change so consecutive changes do not make temporary levels permanent.
+func (h *AdminHandler) apply(level *Level, components map[string]Level, d time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.revert != nil {
+		h.revert.Stop()
+		h.revert = nil
+		h.revertAt = time.Time{}
+	} else {
+		h.revertState = h.levels()
+	}
+	if d > 0 {
+		var t *time.Timer
+		t = time.AfterFunc(d, func() {
+			h.mu.Lock()
+			defer h.mu.Unlock()
+			if h.revert != t {
+				// Superseded by a more recent change
+				return
+			}
+			h.set(h.revertState.Level, h.revertState.Components)
+			h.revert = nil
+			h.revertAt = time.Time{}
+		})
+		h.revert = t
+		h.revertAt = time.Now().Add(d)
+	}
+	if level != nil {
+		h.set(*level, components)
+	} else {
+		h.set(h.Level.Level(), components)
+	}
+}
+
+func (h *AdminHandler) set(level Level, components map[string]Level) {
+	h.Level.SetLevel(level)
+	for c, l := range components {
+		if v := h.Components[c]; v != nil {
+			v.SetLevel(l)
+		}
+	}
+}
+
+// levels returns the current levels without revert information.
+func (h *AdminHandler) levels() adminState {
+	s := adminState{
+		Level: h.Level.Level(),
+	}
+	if len(h.Components) > 0 {
+		s.Components = make(map[string]Level, len(h.Components))
+		for c, v := range h.Components {
+			if v != nil {
+				s.Components[c] = v.Level()
+			}
+		}
+	}
+	return s
+}
+
+func (h *AdminHandler) state() adminState {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s := h.levels()
+	if h.revert != nil {
+		t := h.revertAt
+		s.RevertAt = &t
+	}
+	if len(h.Outputs) > 0 {
+		s.Outputs = make(map[string]OutputChannelStats, len(h.Outputs))
+		for name, oc := range h.Outputs {
+			s.Outputs[name] = oc.Stats()
+		}
+	}
+	return s
+}
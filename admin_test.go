@@ -0,0 +1,113 @@
+package xlog
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdminHandlerGet(t *testing.T) {
+	h := &AdminHandler{Level: NewLevelVar(LevelInfo)}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.Equal(t, "{\"level\":\"info\"}\n", w.Body.String())
+}
+
+func TestAdminHandlerOutputs(t *testing.T) {
+	oc := NewOutputChannelBuffer(Discard, 10)
+	defer oc.Close()
+	h := &AdminHandler{Level: NewLevelVar(LevelInfo), Outputs: map[string]*OutputChannel{"main": oc}}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"outputs":{"main":{"queue_length":0,"capacity":10,`)
+}
+
+func TestAdminHandlerPut(t *testing.T) {
+	lv := NewLevelVar(LevelInfo)
+	h := &AdminHandler{Level: lv}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("PUT", "/", strings.NewReader(`{"level":"warn"}`)))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "{\"level\":\"warn\"}\n", w.Body.String())
+	assert.Equal(t, LevelWarn, lv.Level())
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("PUT", "/", strings.NewReader(`{"level":"invalid"}`)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, LevelWarn, lv.Level())
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("PUT", "/", strings.NewReader(`{"level":"debug","revert_after":"invalid"}`)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, LevelWarn, lv.Level())
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("DELETE", "/", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	assert.Equal(t, "GET, HEAD, PUT", w.Header().Get("Allow"))
+}
+
+func TestAdminHandlerRevert(t *testing.T) {
+	lv := NewLevelVar(LevelInfo)
+	h := &AdminHandler{Level: lv}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("PUT", "/", strings.NewReader(`{"level":"debug","revert_after":"1h"}`)))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"revert_at"`)
+	assert.Equal(t, LevelDebug, lv.Level())
+
+	// A second change keeps the level set before the first one as revert target
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("PUT", "/", strings.NewReader(`{"level":"warn","revert_after":"10ms"}`)))
+	assert.Equal(t, LevelWarn, lv.Level())
+	for i := 0; i < 100 && lv.Level() != LevelInfo; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, LevelInfo, lv.Level())
+	assert.Nil(t, h.state().RevertAt)
+
+	// A change without revert_after cancels the pending revert
+	debug, err := LevelDebug, LevelError
+	h.apply(&debug, nil, time.Hour)
+	h.apply(&err, nil, 0)
+	assert.Nil(t, h.state().RevertAt)
+	assert.Equal(t, LevelError, lv.Level())
+}
+
+func TestAdminHandlerComponents(t *testing.T) {
+	lv := NewLevelVar(LevelInfo)
+	db := NewLevelVar(LevelWarn)
+	h := &AdminHandler{Level: lv, Components: map[string]*LevelVar{"db": db}}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, "{\"level\":\"info\",\"components\":{\"db\":\"warn\"}}\n", w.Body.String())
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("PUT", "/", strings.NewReader(`{"components":{"db":"debug"},"revert_after":"10ms"}`)))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, LevelInfo, lv.Level())
+	assert.Equal(t, LevelDebug, db.Level())
+	for i := 0; i < 100 && db.Level() != LevelWarn; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, LevelWarn, db.Level())
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("PUT", "/", strings.NewReader(`{"components":{"cache":"debug"}}`)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `unknown component "cache"`)
+}
+
+func TestAdminHandlerNoLevel(t *testing.T) {
+	h := &AdminHandler{}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
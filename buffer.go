@@ -0,0 +1,56 @@
+package xlog
+
+import "sync"
+
+// defaultBufferSize is the default maximum number of messages buffered per
+// request.
+const defaultBufferSize = 100
+
+// requestBuffer holds the messages of a request below a level until a message at
+// or above LevelError is logged.
+type requestBuffer struct {
+	level Leveler
+	size  int
+
+	mu        sync.Mutex
+	msgs      []map[string]interface{}
+	triggered bool
+}
+
+func newRequestBuffer(level Leveler, size int) *requestBuffer {
+	if size <= 0 {
+		size = defaultBufferSize
+	}
+	return &requestBuffer{
+		level: level,
+		size:  size,
+	}
+}
+
+// add buffers the message data if its level is below the buffer's level and no
+// error has been logged yet, in which case buffered is true. When the message
+// is an error, the messages to output before it are returned in flush and all
+// the following messages of the request are output directly.
+func (b *requestBuffer) add(level Level, data map[string]interface{}) (buffered bool, flush []map[string]interface{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.triggered {
+		return false, nil
+	}
+	if level.AtLeast(LevelError) {
+		b.triggered = true
+		flush = b.msgs
+		b.msgs = nil
+		return false, flush
+	}
+	if level.AtLeast(b.level.Level()) {
+		return false, nil
+	}
+	if len(b.msgs) >= b.size {
+		// Discard the oldest message
+		copy(b.msgs, b.msgs[1:])
+		b.msgs = b.msgs[:len(b.msgs)-1]
+	}
+	b.msgs = append(b.msgs, data)
+	return true, nil
+}
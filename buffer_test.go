@@ -0,0 +1,46 @@
+package xlog
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestBuffer(t *testing.T) {
+	b := newRequestBuffer(LevelWarn, 2)
+	buffered, flush := b.add(LevelDebug, F{"message": "1"})
+	assert.True(t, buffered)
+	assert.Nil(t, flush)
+	b.add(LevelInfo, F{"message": "2"})
+	b.add(LevelInfo, F{"message": "3"})
+	buffered, flush = b.add(LevelWarn, F{"message": "4"})
+	assert.False(t, buffered)
+	assert.Nil(t, flush)
+	buffered, flush = b.add(LevelError, F{"message": "5"})
+	assert.False(t, buffered)
+	// The oldest message has been discarded
+	assert.Equal(t, []map[string]interface{}{{"message": "2"}, {"message": "3"}}, flush)
+	buffered, flush = b.add(LevelDebug, F{"message": "6"})
+	assert.False(t, buffered)
+	assert.Nil(t, flush)
+
+	b = newRequestBuffer(LevelWarn, 0)
+	assert.Equal(t, defaultBufferSize, b.size)
+}
+
+func TestSendBuffer(t *testing.T) {
+	o := newTestOutput()
+	l := New(Config{Output: o}).(*logger)
+	l.buffer = newRequestBuffer(LevelWarn, 10)
+	l.Debug("debug")
+	l.Info("info")
+	assert.True(t, o.empty())
+	l.Warn("warn")
+	assert.Equal(t, "warn", o.get()["message"])
+	l.Error("error")
+	assert.Equal(t, "debug", o.get()["message"])
+	assert.Equal(t, "info", o.get()["message"])
+	assert.Equal(t, "error", o.get()["message"])
+	l.Debug("debug")
+	assert.Equal(t, "debug", o.get()["message"])
+}
@@ -0,0 +1,58 @@
+package xlog
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// canonicalLineMessage is the message of the summary output at the end of each
+// request when Config.CanonicalLine is set.
+const canonicalLineMessage = "canonical-log-line"
+
+// levelCounts counts messages per level, like the messages logged by a request
+// logger and its copies.
+type levelCounts struct {
+	n [LevelPanic - LevelTrace + 1]int32
+}
+
+func (c *levelCounts) inc(level Level) {
+	if level >= LevelTrace && level <= LevelPanic {
+		atomic.AddInt32(&c.n[level-LevelTrace], 1)
+	}
+}
+
+// total returns the count of messages of all levels.
+func (c *levelCounts) total() int {
+	n := 0
+	for i := range c.n {
+		n += int(atomic.LoadInt32(&c.n[i]))
+	}
+	return n
+}
+
+// LogValue implements the LogValuer interface, reporting the count of each level
+// with at least one message.
+func (c *levelCounts) LogValue() interface{} {
+	f := F{}
+	for i := range c.n {
+		if n := atomic.LoadInt32(&c.n[i]); n > 0 {
+			f[(Level(i) + LevelTrace).String()] = int(n)
+		}
+	}
+	return f
+}
+
+// canonicalLine outputs the summary of a request with the given status code,
+// response size and duration. The message bypasses the level, the sampler and
+// the request buffer so it is always output.
+func (l *logger) canonicalLine(status int, size int64, d time.Duration) {
+	counts := l.counts
+	l.counts = nil
+	l.buffer = nil
+	l.write(LevelInfo, 2, canonicalLineMessage, F{
+		KeyStatus:   status,
+		KeySize:     size,
+		KeyDuration: d,
+		KeyMessages: counts.LogValue(),
+	}, 1)
+}
@@ -0,0 +1,131 @@
+package xlog
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// TrustedProxies is a list of networks whose addresses are trusted to report the
+// address of the client they forward requests for.
+type TrustedProxies []*net.IPNet
+
+// ParseTrustedProxies parses a list of CIDRs (i.e.: 10.0.0.0/8) or single IP
+// addresses into TrustedProxies.
+func ParseTrustedProxies(cidrs ...string) (TrustedProxies, error) {
+	tp := make(TrustedProxies, 0, len(cidrs))
+	for _, c := range cidrs {
+		if !strings.Contains(c, "/") {
+			if ip := net.ParseIP(c); ip != nil {
+				bits := 8 * net.IPv6len
+				if ip.To4() != nil {
+					bits = 8 * net.IPv4len
+				}
+				tp = append(tp, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+				continue
+			}
+		}
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			return nil, err
+		}
+		tp = append(tp, n)
+	}
+	return tp, nil
+}
+
+// Contains returns true if addr is a valid IP address within one of the trusted
+// networks.
+func (tp TrustedProxies) Contains(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, n := range tp {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP returns the address of the client of r and the addresses of the
+// proxies the request went thru, from the closest to the client to the peer of
+// the connection.
+//
+// The forwarding headers are only used if the peer is trusted, in this order of
+// preference: Forwarded, X-Forwarded-For then X-Real-IP. The forwarding chain is
+// walked from the peer toward the client as long as the addresses are trusted,
+// the first untrusted address being the client's.
+func clientIP(r *http.Request, trusted TrustedProxies) (client string, proxies []string) {
+	peer := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(peer); err == nil {
+		peer = host
+	}
+	if !trusted.Contains(peer) {
+		return peer, nil
+	}
+	var chain []string
+	if v := r.Header["Forwarded"]; len(v) > 0 {
+		chain = parseForwarded(v)
+	} else if v := r.Header["X-Forwarded-For"]; len(v) > 0 {
+		for _, l := range v {
+			for _, n := range strings.Split(l, ",") {
+				if n = forwardedNode(n); n != "" {
+					chain = append(chain, n)
+				}
+			}
+		}
+	} else if v := r.Header.Get("X-Real-Ip"); v != "" {
+		chain = []string{forwardedNode(v)}
+	}
+	if len(chain) == 0 {
+		return peer, nil
+	}
+	chain = append(chain, peer)
+	i := len(chain) - 1
+	for i > 0 && trusted.Contains(chain[i]) {
+		i--
+	}
+	return chain[i], chain[i+1:]
+}
+
+// parseForwarded returns the "for" parameters of RFC 7239 Forwarded headers.
+func parseForwarded(headers []string) []string {
+	var chain []string
+	for _, h := range headers {
+		for _, elem := range strings.Split(h, ",") {
+			for _, pair := range strings.Split(elem, ";") {
+				kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+				if len(kv) == 2 && strings.EqualFold(kv[0], "for") {
+					if n := forwardedNode(kv[1]); n != "" {
+						chain = append(chain, n)
+					}
+				}
+			}
+		}
+	}
+	return chain
+}
+
+// forwardedNode returns the address of a node reported by a forwarding header,
+// without quotes, brackets or port.
+func forwardedNode(n string) string {
+	n = strings.Trim(strings.TrimSpace(n), `"`)
+	if host, _, err := net.SplitHostPort(n); err == nil {
+		return host
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(n, "["), "]")
+}
+
+// setClientIPFields adds the client address of r and the proxies it went thru to
+// l using the given field names, if not empty.
+func setClientIPFields(l Logger, r *http.Request, trusted TrustedProxies, name, proxiesName string) {
+	client, proxies := clientIP(r, trusted)
+	if name != "" && client != "" {
+		l.SetField(name, client)
+	}
+	if proxiesName != "" && len(proxies) > 0 {
+		l.SetField(proxiesName, proxies)
+	}
+}
@@ -0,0 +1,53 @@
+package xlog
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTrustedProxies(t *testing.T) {
+	tp, err := ParseTrustedProxies("10.0.0.0/8", "192.168.1.1", "fd00::/8", "::1")
+	assert.NoError(t, err)
+	assert.True(t, tp.Contains("10.1.2.3"))
+	assert.True(t, tp.Contains("192.168.1.1"))
+	assert.False(t, tp.Contains("192.168.1.2"))
+	assert.True(t, tp.Contains("fd00::1"))
+	assert.True(t, tp.Contains("::1"))
+	assert.False(t, tp.Contains("::2"))
+	assert.False(t, tp.Contains("unknown"))
+	_, err = ParseTrustedProxies("10.0.0.0/33")
+	assert.Error(t, err)
+	_, err = ParseTrustedProxies("foo")
+	assert.Error(t, err)
+}
+
+func TestClientIP(t *testing.T) {
+	tp, _ := ParseTrustedProxies("10.0.0.0/8", "fd00::/8")
+	tests := []struct {
+		remoteAddr string
+		header     http.Header
+		client     string
+		proxies    []string
+	}{
+		{"1.2.3.4:1234", nil, "1.2.3.4", nil},
+		// Untrusted peer
+		{"1.2.3.4:1234", http.Header{"X-Forwarded-For": {"5.6.7.8"}}, "1.2.3.4", nil},
+		{"10.0.0.1:1234", nil, "10.0.0.1", nil},
+		{"10.0.0.1:1234", http.Header{"X-Forwarded-For": {"5.6.7.8"}}, "5.6.7.8", []string{"10.0.0.1"}},
+		// Spoofed addresses left of the first untrusted one are ignored
+		{"10.0.0.1:1234", http.Header{"X-Forwarded-For": {"9.9.9.9, 5.6.7.8", "10.0.0.2"}}, "5.6.7.8", []string{"10.0.0.2", "10.0.0.1"}},
+		{"10.0.0.1:1234", http.Header{"X-Forwarded-For": {"10.0.0.3, 10.0.0.2"}}, "10.0.0.3", []string{"10.0.0.2", "10.0.0.1"}},
+		{"10.0.0.1:1234", http.Header{"X-Real-Ip": {"5.6.7.8"}}, "5.6.7.8", []string{"10.0.0.1"}},
+		{"[fd00::1]:1234", http.Header{"Forwarded": {`for=192.0.2.60;proto=http;by=203.0.113.43, For="[2001:db8:cafe::17]:4711"`}},
+			"2001:db8:cafe::17", []string{"fd00::1"}},
+		{"10.0.0.1:1234", http.Header{"Forwarded": {"for=unknown, for=10.0.0.2"}, "X-Forwarded-For": {"5.6.7.8"}},
+			"unknown", []string{"10.0.0.2", "10.0.0.1"}},
+	}
+	for _, tt := range tests {
+		client, proxies := clientIP(&http.Request{RemoteAddr: tt.remoteAddr, Header: tt.header}, tp)
+		assert.Equal(t, tt.client, client, "%s %v", tt.remoteAddr, tt.header)
+		assert.Equal(t, tt.proxies, proxies, "%s %v", tt.remoteAddr, tt.header)
+	}
+}
@@ -0,0 +1,118 @@
+package xlog
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DedupOutput is an Output suppressing identical messages. The first message is
+// forwarded to the next output while its duplicates received during the
+// following window are discarded. When the window closes, or when the output is
+// flushed, a copy of the first message with a KeyRepeated field set to the
+// number of duplicates suppressed is sent.
+//
+// Messages are identical if they have the same level, message and values for the
+// configured fields.
+type DedupOutput struct {
+	window time.Duration
+	fields []string
+	output Output
+
+	mu      sync.Mutex
+	entries map[string]*dedupEntry
+}
+
+type dedupEntry struct {
+	first    map[string]interface{}
+	repeated int
+	timer    *time.Timer
+}
+
+// NewDedupOutput returns an output forwarding messages to o and suppressing
+// identical messages received during window. The fields parameter lists the
+// fields, in addition to the level and message, compared to decide if messages
+// are identical.
+func NewDedupOutput(window time.Duration, fields []string, o Output) *DedupOutput {
+	return &DedupOutput{
+		window:  window,
+		fields:  fields,
+		output:  o,
+		entries: map[string]*dedupEntry{},
+	}
+}
+
+// key returns the string identifying identical messages.
+func (d *DedupOutput) key(fields map[string]interface{}) string {
+	k := make([]string, 0, 2+len(d.fields))
+	k = append(k, fmt.Sprint(fields[KeyLevel]), fmt.Sprint(fields[KeyMessage]))
+	for _, f := range d.fields {
+		k = append(k, fmt.Sprint(fields[f]))
+	}
+	return strings.Join(k, "\x00")
+}
+
+// Write implements the Output interface
+func (d *DedupOutput) Write(fields map[string]interface{}) error {
+	key := d.key(fields)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if e, found := d.entries[key]; found {
+		e.repeated++
+		return nil
+	}
+	// Keep a copy of the first message as next outputs may modify it
+	e := &dedupEntry{first: make(map[string]interface{}, len(fields))}
+	for k, v := range fields {
+		e.first[k] = v
+	}
+	e.timer = time.AfterFunc(d.window, func() {
+		d.expire(key, e)
+	})
+	d.entries[key] = e
+	return d.output.Write(fields)
+}
+
+// expire closes the window of the entry.
+func (d *DedupOutput) expire(key string, e *dedupEntry) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.entries[key] != e {
+		// Already flushed
+		return
+	}
+	delete(d.entries, key)
+	if err := d.writeSummary(e); err != nil {
+		critialLogger.Print("cannot write log message: ", err.Error())
+	}
+}
+
+// writeSummary sends the summary message of the entry if duplicates have been
+// suppressed.
+func (d *DedupOutput) writeSummary(e *dedupEntry) error {
+	if e.repeated == 0 {
+		return nil
+	}
+	summary := make(map[string]interface{}, len(e.first)+1)
+	for k, v := range e.first {
+		summary[k] = v
+	}
+	summary[KeyTime] = now()
+	summary[KeyRepeated] = e.repeated
+	return d.output.Write(summary)
+}
+
+// Flush closes all the pending windows, sending the summary messages of
+// suppressed duplicates.
+func (d *DedupOutput) Flush() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for key, e := range d.entries {
+		e.timer.Stop()
+		delete(d.entries, key)
+		if err := d.writeSummary(e); err != nil {
+			critialLogger.Print("cannot write log message: ", err.Error())
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package xlog
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDedupOutput(t *testing.T) {
+	o := newTestOutput()
+	d := NewDedupOutput(time.Hour, []string{"host"}, o)
+	assert.NoError(t, d.Write(F{"level": "error", "message": "down", "host": "a", "foo": "bar"}))
+	assert.Equal(t, F{"level": "error", "message": "down", "host": "a", "foo": "bar"}, F(o.get()))
+	assert.NoError(t, d.Write(F{"level": "error", "message": "down", "host": "a", "foo": "baz"}))
+	assert.NoError(t, d.Write(F{"level": "error", "message": "down", "host": "a"}))
+	assert.True(t, o.empty())
+	// Different field value, level or message are not duplicates
+	assert.NoError(t, d.Write(F{"level": "error", "message": "down", "host": "b"}))
+	assert.Equal(t, "b", o.get()["host"])
+	assert.NoError(t, d.Write(F{"level": "warn", "message": "down", "host": "a"}))
+	assert.Equal(t, "warn", o.get()["level"])
+	assert.NoError(t, d.Write(F{"level": "error", "message": "up", "host": "a"}))
+	assert.Equal(t, "up", o.get()["message"])
+
+	d.Flush()
+	assert.Equal(t, F{"level": "error", "message": "down", "host": "a", "foo": "bar", "time": fakeNow, "repeated": 2}, F(o.get()))
+	// No summary for messages without duplicates
+	assert.True(t, o.empty())
+
+	// Window is reset after a flush
+	assert.NoError(t, d.Write(F{"level": "error", "message": "down", "host": "a"}))
+	assert.Equal(t, "down", o.get()["message"])
+}
+
+func TestDedupOutputWindow(t *testing.T) {
+	o := newTestOutput()
+	d := NewDedupOutput(20*time.Millisecond, nil, o)
+	assert.NoError(t, d.Write(F{"level": "error", "message": "down"}))
+	assert.NoError(t, d.Write(F{"level": "error", "message": "down"}))
+	assert.Equal(t, F{"level": "error", "message": "down"}, F(o.get()))
+	assert.Equal(t, F{"level": "error", "message": "down", "time": fakeNow, "repeated": 1}, F(o.get()))
+	d.mu.Lock()
+	assert.Len(t, d.entries, 0)
+	d.mu.Unlock()
+}
+
+func TestDedupOutputChannelClose(t *testing.T) {
+	o := newTestOutput()
+	oc := NewOutputChannel(NewDedupOutput(time.Hour, nil, o))
+	oc.Write(F{"level": "error", "message": "down"})
+	oc.Write(F{"level": "error", "message": "down"})
+	oc.Close()
+	assert.Equal(t, F{"level": "error", "message": "down"}, F(o.get()))
+	assert.Equal(t, 1, o.get()["repeated"])
+}
@@ -0,0 +1,103 @@
+package xlog
+
+import (
+	"reflect"
+	"strconv"
+)
+
+// ErrorFielder is implemented by errors providing structured fields to log
+// along with their message when they are set as a field value.
+type ErrorFielder interface {
+	ErrorFields() F
+}
+
+// Keys of the structured representation of errors.
+const (
+	errorKeyMessage = "message"
+	errorKeyType    = "type"
+	errorKeyCause   = "cause"
+	errorKeyCauses  = "causes"
+)
+
+// maxErrorDepth limits the depth of the cause chain reported for an error.
+const maxErrorDepth = 16
+
+// isNilError returns true if err holds a nil pointer, map, slice, func or chan,
+// like a typed nil pointer whose Error method would dereference its receiver.
+func isNilError(err error) bool {
+	switch v := reflect.ValueOf(err); v.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan:
+		return v.IsNil()
+	}
+	return false
+}
+
+// errorValue returns the structured representation of err: its message, concrete
+// type, the fields it provides if it implements ErrorFielder and the errors it
+// wraps. A single wrapped error (Unwrap() error) is reported as cause while
+// multiple wrapped errors (Unwrap() []error as created by errors.Join) are
+// reported as causes.
+func errorValue(err error, depth int) map[string]interface{} {
+	v := map[string]interface{}{
+		errorKeyMessage: err.Error(),
+		errorKeyType:    reflect.TypeOf(err).String(),
+	}
+	if ef, ok := err.(ErrorFielder); ok {
+		for k, fv := range ef.ErrorFields() {
+			if _, reserved := v[k]; !reserved && k != errorKeyCause && k != errorKeyCauses {
+				v[k] = fv
+			}
+		}
+	}
+	if depth >= maxErrorDepth {
+		return v
+	}
+	switch e := err.(type) {
+	case interface {
+		Unwrap() []error
+	}:
+		causes := []interface{}{}
+		for _, c := range e.Unwrap() {
+			if c != nil && !isNilError(c) {
+				causes = append(causes, errorValue(c, depth+1))
+			}
+		}
+		if len(causes) > 0 {
+			v[errorKeyCauses] = causes
+		}
+	case interface {
+		Unwrap() error
+	}:
+		if c := e.Unwrap(); c != nil && !isNilError(c) {
+			v[errorKeyCause] = errorValue(c, depth+1)
+		}
+	}
+	return v
+}
+
+// flattenErrorValue adds the structured representation of an error v to dst
+// using dotted keys prefixed by key for line based outputs like logfmt. The
+// message of the error is set as key, its type as key.type, its cause's message
+// as key.cause and so on.
+func flattenErrorValue(dst map[string]interface{}, key string, v map[string]interface{}) {
+	for k, fv := range v {
+		switch k {
+		case errorKeyMessage:
+			dst[key] = fv
+		case errorKeyCause:
+			if c, ok := fv.(map[string]interface{}); ok {
+				flattenErrorValue(dst, key+"."+errorKeyCause, c)
+			}
+		case errorKeyCauses:
+			if cs, ok := fv.([]interface{}); ok {
+				for i, c := range cs {
+					if c, ok := c.(map[string]interface{}); ok {
+						flattenErrorValue(dst, key+"."+errorKeyCauses+"."+strconv.Itoa(i), c)
+					}
+				}
+			}
+		default:
+			dst[key+"."+k] = fv
+		}
+	}
+}
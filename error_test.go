@@ -0,0 +1,191 @@
+package xlog
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testWrapError struct {
+	msg   string
+	cause error
+}
+
+func (e testWrapError) Error() string { return e.msg + ": " + e.cause.Error() }
+func (e testWrapError) Unwrap() error { return e.cause }
+
+type testJoinError []error
+
+func (e testJoinError) Error() string   { return "multiple errors" }
+func (e testJoinError) Unwrap() []error { return e }
+
+type testFieldsError struct{}
+
+func (e *testFieldsError) Error() string { return "with fields" }
+func (e *testFieldsError) ErrorFields() F {
+	return F{"code": 42, "message": "overridden", "cause": "overridden"}
+}
+
+type testNilError struct {
+	msg string
+}
+
+func (e *testNilError) Error() string { return e.msg }
+
+func TestErrorValue(t *testing.T) {
+	assert.Equal(t, map[string]interface{}{
+		"message": "some error",
+		"type":    "*errors.errorString",
+	}, errorValue(errors.New("some error"), 0))
+
+	err := testWrapError{"wrapped", errors.New("cause")}
+	assert.Equal(t, map[string]interface{}{
+		"message": "wrapped: cause",
+		"type":    "xlog.testWrapError",
+		"cause": map[string]interface{}{
+			"message": "cause",
+			"type":    "*errors.errorString",
+		},
+	}, errorValue(err, 0))
+
+	join := testJoinError{errors.New("first"), nil, &testFieldsError{}}
+	assert.Equal(t, map[string]interface{}{
+		"message": "multiple errors",
+		"type":    "xlog.testJoinError",
+		"causes": []interface{}{
+			map[string]interface{}{
+				"message": "first",
+				"type":    "*errors.errorString",
+			},
+			map[string]interface{}{
+				"message": "with fields",
+				"type":    "*xlog.testFieldsError",
+				"code":    42,
+			},
+		},
+	}, errorValue(join, 0))
+}
+
+func TestErrorValueMaxDepth(t *testing.T) {
+	var err error = errors.New("root")
+	for i := 0; i < maxErrorDepth*2; i++ {
+		err = testWrapError{"wrap", err}
+	}
+	v := errorValue(err, 0)
+	depth := 0
+	for {
+		c, ok := v["cause"].(map[string]interface{})
+		if !ok {
+			break
+		}
+		v = c
+		depth++
+	}
+	assert.Equal(t, maxErrorDepth, depth)
+}
+
+func TestResolveFieldsErrors(t *testing.T) {
+	fields := map[string]interface{}{"foo": "bar"}
+	assert.Equal(t, fields, resolveFields(fields, false))
+
+	fields = map[string]interface{}{
+		"foo": "bar",
+		"err": testWrapError{"wrapped", errors.New("cause")},
+	}
+	assert.Equal(t, map[string]interface{}{
+		"foo": "bar",
+		"err": map[string]interface{}{
+			"message": "wrapped: cause",
+			"type":    "xlog.testWrapError",
+			"cause": map[string]interface{}{
+				"message": "cause",
+				"type":    "*errors.errorString",
+			},
+		},
+	}, resolveFields(fields, false))
+	assert.Equal(t, map[string]interface{}{
+		"foo":            "bar",
+		"err":            "wrapped: cause",
+		"err.type":       "xlog.testWrapError",
+		"err.cause":      "cause",
+		"err.cause.type": "*errors.errorString",
+	}, resolveFields(fields, true))
+	// Original fields are not modified
+	assert.IsType(t, testWrapError{}, fields["err"])
+
+	fields = map[string]interface{}{
+		"err": testJoinError{errors.New("first"), &testFieldsError{}},
+	}
+	assert.Equal(t, map[string]interface{}{
+		"err":               "multiple errors",
+		"err.type":          "xlog.testJoinError",
+		"err.causes.0":      "first",
+		"err.causes.0.type": "*errors.errorString",
+		"err.causes.1":      "with fields",
+		"err.causes.1.type": "*xlog.testFieldsError",
+		"err.causes.1.code": 42,
+	}, resolveFields(fields, true))
+}
+
+func TestResolveFieldsNilErrors(t *testing.T) {
+	var nilErr *testNilError
+	var nilJoin testJoinError
+	fields := map[string]interface{}{
+		"err":     nilErr,
+		"join":    nilJoin,
+		"wrapped": testWrapErrorNilCause{},
+		"causes":  testJoinError{nilErr, errors.New("cause")},
+	}
+	assert.Equal(t, map[string]interface{}{
+		"err":     nil,
+		"join":    nil,
+		"wrapped": map[string]interface{}{"message": "nil cause", "type": "xlog.testWrapErrorNilCause"},
+		"causes": map[string]interface{}{
+			"message": "multiple errors",
+			"type":    "xlog.testJoinError",
+			"causes":  []interface{}{map[string]interface{}{"message": "cause", "type": "*errors.errorString"}},
+		},
+	}, resolveFields(fields, false))
+
+	buf := &bytes.Buffer{}
+	assert.NoError(t, NewJSONOutput(buf).Write(F{"err": nilErr}))
+	assert.Equal(t, `{"err":null}`+"\n", buf.String())
+}
+
+type testWrapErrorNilCause struct{}
+
+func (e testWrapErrorNilCause) Error() string { return "nil cause" }
+func (e testWrapErrorNilCause) Unwrap() error {
+	var err *testNilError
+	return err
+}
+
+func TestJSONOutputError(t *testing.T) {
+	buf := &bytes.Buffer{}
+	j := NewJSONOutput(buf)
+	err := j.Write(F{"message": "some message", "err": testWrapError{"wrapped", errors.New("cause")}})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"err":{"cause":{"message":"cause","type":"*errors.errorString"},"message":"wrapped: cause","type":"xlog.testWrapError"},"message":"some message"}`+"\n", buf.String())
+}
+
+func TestLogstashOutputError(t *testing.T) {
+	buf := &bytes.Buffer{}
+	o := NewLogstashOutput(buf)
+	err := o.Write(F{"message": "some message", "err": errors.New("some error")})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"@version":1,"err":{"message":"some error","type":"*errors.errorString"},"message":"some message"}`, buf.String())
+}
+
+func TestConsoleOutputError(t *testing.T) {
+	buf := &bytes.Buffer{}
+	c := consoleOutput{w: buf}
+	err := c.Write(F{"message": "some message", "level": "error", "err": testWrapError{"wrapped", errors.New("cause")}})
+	assert.NoError(t, err)
+	assert.Equal(t, "\x1b[31mERRO\x1b[0m some message "+
+		"\x1b[32merr\x1b[0m=\"wrapped: cause\" "+
+		"\x1b[32merr.cause\x1b[0m=cause "+
+		"\x1b[32merr.cause.type\x1b[0m=*errors.errorString "+
+		"\x1b[32merr.type\x1b[0m=xlog.testWrapError\n", buf.String())
+}
@@ -16,14 +16,33 @@ type key int
 const (
 	logKey key = iota
 	idKey
+	traceKey
 )
 
-// IDFromContext returns the unique id associated to the request if any.
+// IDFromContext returns the unique id associated to the request if any and if it
+// is an xid. Use RequestIDFromContext to get ids of any format.
 func IDFromContext(ctx context.Context) (xid.ID, bool) {
-	id, ok := ctx.Value(idKey).(xid.ID)
+	s, ok := RequestIDFromContext(ctx)
+	if !ok {
+		return xid.ID{}, false
+	}
+	id, err := xid.FromString(s)
+	return id, err == nil
+}
+
+// RequestIDFromContext returns the unique id associated to the request if any,
+// whatever its format.
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(idKey).(string)
 	return id, ok
 }
 
+// TraceFromContext returns the tracing context associated to the request if any.
+func TraceFromContext(ctx context.Context) (TraceContext, bool) {
+	tc, ok := ctx.Value(traceKey).(TraceContext)
+	return tc, ok
+}
+
 // FromContext gets the logger out of the context.
 // If not logger is stored in the context, a NopLogger is returned.
 func FromContext(ctx context.Context) Logger {
@@ -45,18 +64,39 @@ func NewContext(ctx context.Context, l Logger) context.Context {
 // NewHandler instanciates a new xlog HTTP handler.
 //
 // If not configured, the output is set to NewConsoleOutput() by default.
+//
+// If c.BufferLevel is set, the messages of each request below this level are
+// only output if an error is logged during the request.
+//
+// If c.CanonicalLine is set, a summary of each request is output once the
+// request has been handled.
 func NewHandler(c Config) func(xhandler.HandlerC) xhandler.HandlerC {
 	if c.Output == nil {
 		c.Output = NewOutputChannel(NewConsoleOutput())
 	}
 	return func(next xhandler.HandlerC) xhandler.HandlerC {
 		return xhandler.HandlerFuncC(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			start := now()
 			l := New(c)
+			if c.BufferLevel != nil {
+				l.(*logger).buffer = newRequestBuffer(c.BufferLevel, c.BufferSize)
+			}
+			var rw *responseWriter
+			if c.CanonicalLine {
+				l.(*logger).counts = &levelCounts{}
+				rw = newResponseWriter(w)
+				w = rw.wrap()
+			}
 			ctx = NewContext(ctx, l)
+			defer func() {
+				if l, ok := l.(*logger); ok {
+					if rw != nil {
+						l.canonicalLine(rw.Status(), rw.size, now().Sub(start))
+					}
+					l.close()
+				}
+			}()
 			next.ServeHTTPC(ctx, w, r)
-			if l, ok := l.(*logger); ok {
-				l.close()
-			}
 		})
 	}
 }
@@ -107,6 +147,21 @@ func RemoteAddrHandler(name string) func(next xhandler.HandlerC) xhandler.Handle
 	}
 }
 
+// ClientIPHandler returns a handler setting the request's client address as a field
+// to the current context's logger using the passed name as field name. Unlike
+// RemoteAddrHandler, the Forwarded, X-Forwarded-For and X-Real-IP headers are
+// honored when the peer of the connection is one of the trusted proxies. The
+// addresses of the proxies the request went thru are set as a []string using
+// proxiesName as field name, if not empty.
+func ClientIPHandler(name, proxiesName string, trusted TrustedProxies) func(next xhandler.HandlerC) xhandler.HandlerC {
+	return func(next xhandler.HandlerC) xhandler.HandlerC {
+		return xhandler.HandlerFuncC(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			setClientIPFields(FromContext(ctx), r, trusted, name, proxiesName)
+			next.ServeHTTPC(ctx, w, r)
+		})
+	}
+}
+
 // UserAgentHandler returns a handler setting the request's client's user-agent as
 // a field to the current context's logger using the passed name as field name.
 func UserAgentHandler(name string) func(next xhandler.HandlerC) xhandler.HandlerC {
@@ -134,29 +189,129 @@ func RefererHandler(name string) func(next xhandler.HandlerC) xhandler.HandlerC
 }
 
 // RequestIDHandler returns a handler setting a unique id to the request which can
-// be gathered using IDFromContext(ctx). This generated id is added as a field to the
+// be gathered using RequestIDFromContext(ctx). This id is added as a field to the
 // logger using the passed name as field name. The id is also added as a response
 // header if the headerName is not empty.
 //
+// A new id is generated for each request, the headerName request header being
+// ignored so clients can't choose their id. Use RequestIDHandlerWithOptions with
+// TrustHeader to reuse the ids set by trusted upstream services.
+//
 // The generated id is a URL safe base64 encoded mongo object-id-like unique id.
 // Mongo unique id generation algorithm has been selected as a trade-off between
 // size and ease of use: UUID is less space efficient and snowflake requires machine
-// configuration.
+// configuration. Use RequestIDHandlerWithOptions for other formats.
 func RequestIDHandler(name, headerName string) func(next xhandler.HandlerC) xhandler.HandlerC {
+	return RequestIDHandlerWithOptions(RequestIDOptions{
+		Name:   name,
+		Header: headerName,
+	})
+}
+
+// RequestIDHandlerWithOptions is like RequestIDHandler with a configurable id
+// format and inbound id validation.
+func RequestIDHandlerWithOptions(o RequestIDOptions) func(next xhandler.HandlerC) xhandler.HandlerC {
 	return func(next xhandler.HandlerC) xhandler.HandlerC {
 		return xhandler.HandlerFuncC(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
-			id, ok := IDFromContext(ctx)
+			id, ok := RequestIDFromContext(ctx)
 			if !ok {
-				id = xid.New()
+				id = o.requestID(r.Header)
 				ctx = context.WithValue(ctx, idKey, id)
 			}
-			if name != "" {
-				FromContext(ctx).SetField(name, id)
+			if o.Name != "" {
+				FromContext(ctx).SetField(o.Name, id)
 			}
-			if headerName != "" {
-				w.Header().Set(headerName, id.String())
+			if o.Header != "" {
+				w.Header().Set(o.Header, id)
 			}
 			next.ServeHTTPC(ctx, w, r)
 		})
 	}
 }
+
+// TraceHandler returns a handler setting the distributed tracing context of the
+// request, which can be gathered using TraceFromContext(ctx). The context is read
+// from the W3C Trace Context (traceparent and tracestate) headers or from the B3
+// headers. A new trace id is generated if the request has none and a new span id
+// is generated for each request, the span id received being kept as parent.
+//
+// The trace and span ids are added as fields to the logger using the passed
+// names as field names, so logs can be joined with traces. Empty names disable
+// the corresponding field.
+func TraceHandler(traceName, spanName string) func(next xhandler.HandlerC) xhandler.HandlerC {
+	return func(next xhandler.HandlerC) xhandler.HandlerC {
+		return xhandler.HandlerFuncC(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			tc, ok := TraceFromContext(ctx)
+			if !ok {
+				tc = newTraceContext(r.Header)
+				ctx = context.WithValue(ctx, traceKey, tc)
+			}
+			setTraceFields(FromContext(ctx), tc, traceName, spanName)
+			next.ServeHTTPC(ctx, w, r)
+		})
+	}
+}
+
+// SamplingHandler returns a handler sampling the messages of a ratio of the
+// requests: the messages below level of a sampled request are all output while
+// those of the other requests are discarded. Messages at or above level are
+// always output. Sampled messages are annotated with the KeySampleRate field.
+//
+// The sampling decision is taken by hashing the request id so all the messages
+// of a request are kept together. This handler should thus be installed after
+// RequestIDHandler.
+func SamplingHandler(ratio float64, level Level) func(next xhandler.HandlerC) xhandler.HandlerC {
+	return func(next xhandler.HandlerC) xhandler.HandlerC {
+		return xhandler.HandlerFuncC(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			if l, ok := FromContext(ctx).(*logger); ok {
+				id, _ := RequestIDFromContext(ctx)
+				l.sampler = newRequestSampler(id, ratio, level, l.sampler)
+			}
+			next.ServeHTTPC(ctx, w, r)
+		})
+	}
+}
+
+// AccessHandler returns a handler logging every request thru the request's logger
+// once it has been handled. The message holds the method, URL and status code of
+// the request along with the KeyStatus, KeySize and KeyDuration fields. Requests
+// answered with a 5xx status are logged at LevelError, those with a 4xx status at
+// LevelWarn and the others at LevelInfo.
+//
+// The http.ResponseWriter passed to the next handler is wrapped to record the
+// status and size of the response. The wrapper implements the same optional
+// interfaces as the original writer among http.Flusher, http.Hijacker and
+// io.ReaderFrom.
+func AccessHandler() func(next xhandler.HandlerC) xhandler.HandlerC {
+	return func(next xhandler.HandlerC) xhandler.HandlerC {
+		return xhandler.HandlerFuncC(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			start := now()
+			rw := newResponseWriter(w)
+			next.ServeHTTPC(ctx, rw.wrap(), r)
+			logAccess(FromContext(ctx), r, rw, now().Sub(start))
+		})
+	}
+}
+
+// RecoverHandler returns a handler recovering from the panics of the next
+// handlers. The panic is logged thru the request's logger at LevelPanic with the
+// stack trace of the panicking go routine in the KeyStack field, and a 500
+// Internal Server Error response is sent unless the response has already been
+// started. It must be installed after NewHandler for the message to hold the
+// request's fields.
+func RecoverHandler() func(next xhandler.HandlerC) xhandler.HandlerC {
+	return func(next xhandler.HandlerC) xhandler.HandlerC {
+		return xhandler.HandlerFuncC(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			rw := newResponseWriter(w)
+			defer func() {
+				if v := recover(); v != nil {
+					logPanic(FromContext(ctx), v)
+					if rw.status == 0 {
+						http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+					}
+				}
+			}()
+			next.ServeHTTPC(ctx, rw.wrap(), r)
+		})
+	}
+}
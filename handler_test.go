@@ -8,7 +8,9 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"testing"
+	"time"
 
+	"github.com/rs/xid"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -40,6 +42,59 @@ func TestNewHandler(t *testing.T) {
 	h.ServeHTTP(nil, &http.Request{})
 }
 
+func TestNewHandlerBuffer(t *testing.T) {
+	o := newTestOutput()
+	c := Config{
+		Output:      o,
+		BufferLevel: LevelWarn,
+	}
+	lh := NewHandler(c)
+	h := lh(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		l := FromRequest(r)
+		l.Info("info")
+		assert.True(t, o.empty())
+		if r.URL.Path == "/error" {
+			l.Error("error")
+			assert.Equal(t, "info", o.get()["message"])
+			assert.Equal(t, "error", o.get()["message"])
+		}
+	}))
+	h.ServeHTTP(nil, &http.Request{URL: &url.URL{Path: "/"}})
+	assert.True(t, o.empty())
+	h.ServeHTTP(nil, &http.Request{URL: &url.URL{Path: "/error"}})
+}
+
+func TestNewHandlerCanonicalLine(t *testing.T) {
+	o := newTestOutput()
+	c := Config{
+		Output:        o,
+		Level:         LevelWarn,
+		Caller:        CallerDisabled,
+		BufferLevel:   LevelError,
+		CanonicalLine: true,
+	}
+	lh := NewHandler(c)
+	h := lh(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		l := FromRequest(r)
+		l.SetField("foo", "bar")
+		l.Info("info")
+		l.Warn("warn")
+		Copy(l).Warn("warn")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("not found"))
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), &http.Request{})
+	last := o.get()
+	assert.True(t, o.empty())
+	assert.Equal(t, "canonical-log-line", last["message"])
+	assert.Equal(t, "info", last["level"])
+	assert.Equal(t, "bar", last["foo"])
+	assert.Equal(t, http.StatusNotFound, last["status"])
+	assert.Equal(t, int64(9), last["size"])
+	assert.Equal(t, time.Duration(0), last["duration"])
+	assert.Equal(t, F{"warn": 2}, last["messages"])
+}
+
 func TestURLHandler(t *testing.T) {
 	r := &http.Request{
 		URL: &url.URL{Path: "/path", RawQuery: "foo=bar"},
@@ -101,6 +156,20 @@ func TestRemoteAddrHandlerIPv6(t *testing.T) {
 	h.ServeHTTP(nil, r)
 }
 
+func TestClientIPHandler(t *testing.T) {
+	r := &http.Request{
+		RemoteAddr: "10.0.0.1:1234",
+		Header:     http.Header{"X-Forwarded-For": {"1.2.3.4"}},
+	}
+	tp, _ := ParseTrustedProxies("10.0.0.0/8")
+	h := ClientIPHandler("ip", "proxies", tp)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		l := FromRequest(r).(*logger)
+		assert.Equal(t, F{"ip": "1.2.3.4", "proxies": []string{"10.0.0.1"}}, F(l.fields))
+	}))
+	h = NewHandler(Config{})(h)
+	h.ServeHTTP(nil, r)
+}
+
 func TestUserAgentHandler(t *testing.T) {
 	r := &http.Request{
 		Header: http.Header{
@@ -134,13 +203,164 @@ func TestRequestIDHandler(t *testing.T) {
 	h := RequestIDHandler("id", "Request-Id")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		l := FromRequest(r).(*logger)
 		if id, ok := IDFromRequest(r); assert.True(t, ok) {
-			assert.Equal(t, l.fields["id"], id)
+			assert.Equal(t, l.fields["id"], id.String())
 			assert.Len(t, id.String(), 20)
 			assert.Equal(t, id.String(), w.Header().Get("Request-Id"))
 		}
-		assert.Len(t, l.fields["id"], 12)
+		assert.Len(t, l.fields["id"], 20)
 	}))
 	h = NewHandler(Config{})(h)
 	w := httptest.NewRecorder()
 	h.ServeHTTP(w, r)
 }
+
+func TestRequestIDHandlerInbound(t *testing.T) {
+	var id string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, _ = RequestIDFromRequest(r)
+	})
+	in := xid.New().String()
+
+	// Inbound ids are ignored unless trusted
+	h := NewHandler(Config{})(RequestIDHandler("id", "Request-Id")(next))
+	h.ServeHTTP(httptest.NewRecorder(), &http.Request{Header: http.Header{"Request-Id": {in}}})
+	assert.NotEqual(t, in, id)
+	assert.Len(t, id, 20)
+
+	h = NewHandler(Config{})(RequestIDHandlerWithOptions(RequestIDOptions{Name: "id", Header: "Request-Id", TrustHeader: true})(next))
+	h.ServeHTTP(httptest.NewRecorder(), &http.Request{Header: http.Header{"Request-Id": {in}}})
+	assert.Equal(t, in, id)
+	h.ServeHTTP(httptest.NewRecorder(), &http.Request{Header: http.Header{"Request-Id": {"invalid"}}})
+	assert.NotEqual(t, "invalid", id)
+	assert.Len(t, id, 20)
+}
+
+func TestRequestIDHandlerWithOptions(t *testing.T) {
+	var id string
+	var xidOK bool
+	h := RequestIDHandlerWithOptions(RequestIDOptions{
+		Name:        "id",
+		Header:      "Request-Id",
+		TrustHeader: true,
+		Generator:   UUIDv4Generator,
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, _ = RequestIDFromRequest(r)
+		_, xidOK = IDFromRequest(r)
+		assert.Equal(t, id, FromRequest(r).(*logger).fields["id"])
+	}))
+	h = NewHandler(Config{})(h)
+	in := "f47ac10b-58cc-4372-a567-0e02b2c3d479"
+	h.ServeHTTP(httptest.NewRecorder(), &http.Request{Header: http.Header{"Request-Id": {in}}})
+	assert.Equal(t, in, id)
+	assert.False(t, xidOK)
+	h.ServeHTTP(httptest.NewRecorder(), &http.Request{Header: http.Header{"Request-Id": {xid.New().String()}}})
+	assert.True(t, UUIDv4Generator.Valid(id))
+}
+
+func TestTraceHandler(t *testing.T) {
+	r := &http.Request{Header: http.Header{"Traceparent": {"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}}}
+	h := TraceHandler("trace_id", "span_id")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		l := FromRequest(r).(*logger)
+		if tc, ok := TraceFromRequest(r); assert.True(t, ok) {
+			assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", tc.TraceID.String())
+			assert.Equal(t, "00f067aa0ba902b7", tc.ParentSpanID.String())
+			assert.Equal(t, F{"trace_id": tc.TraceID.String(), "span_id": tc.SpanID.String()}, F(l.fields))
+		}
+	}))
+	h = NewHandler(Config{})(h)
+	h.ServeHTTP(nil, r)
+}
+
+func TestSamplingHandler(t *testing.T) {
+	o := newTestOutput()
+	for _, ratio := range []float64{0, 1} {
+		h := SamplingHandler(ratio, LevelWarn)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			l := FromRequest(r)
+			l.Info("info")
+			if ratio == 1 {
+				assert.Equal(t, "info", o.get()["message"])
+			}
+			assert.True(t, o.empty())
+			l.Warn("warn")
+			assert.Equal(t, "warn", o.get()["message"])
+		}))
+		h = RequestIDHandler("id", "")(h)
+		h = NewHandler(Config{Output: o})(h)
+		h.ServeHTTP(httptest.NewRecorder(), &http.Request{})
+	}
+}
+
+func TestAccessHandler(t *testing.T) {
+	o := newTestOutput()
+	h := AccessHandler()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/notfound":
+			http.NotFound(w, r)
+		case "/error":
+			w.WriteHeader(http.StatusBadGateway)
+		default:
+			w.Write([]byte("ok"))
+		}
+	}))
+	h = NewHandler(Config{Output: o, Caller: CallerDisabled})(h)
+	h.ServeHTTP(httptest.NewRecorder(), &http.Request{Method: "GET", URL: &url.URL{Path: "/"}})
+	assert.Equal(t, map[string]interface{}{"time": fakeNow, "level": "info", "message": "GET / 200", "status": 200, "size": int64(2), "duration": time.Duration(0)}, o.get())
+	h.ServeHTTP(httptest.NewRecorder(), &http.Request{Method: "GET", URL: &url.URL{Path: "/notfound"}})
+	assert.Equal(t, map[string]interface{}{"time": fakeNow, "level": "warn", "message": "GET /notfound 404", "status": 404, "size": int64(19), "duration": time.Duration(0)}, o.get())
+	h.ServeHTTP(httptest.NewRecorder(), &http.Request{Method: "POST", URL: &url.URL{Path: "/error"}})
+	assert.Equal(t, map[string]interface{}{"time": fakeNow, "level": "error", "message": "POST /error 502", "status": 502, "size": int64(0), "duration": time.Duration(0)}, o.get())
+}
+
+func TestNewHandlerPanic(t *testing.T) {
+	var l *logger
+	h := NewHandler(Config{Output: newTestOutput()})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		l = FromRequest(r).(*logger)
+		panic("boom")
+	}))
+	assert.Panics(t, func() {
+		h.ServeHTTP(nil, &http.Request{})
+	})
+	// The logger has been released
+	assert.Nil(t, l.output)
+}
+
+func TestRecoverHandler(t *testing.T) {
+	o := newTestOutput()
+	h := RecoverHandler()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		FromRequest(r).SetField("foo", "bar")
+		if r.URL.Path == "/started" {
+			w.WriteHeader(http.StatusAccepted)
+		}
+		panic("boom")
+	}))
+	h = NewHandler(Config{Output: o, Caller: CallerDisabled})(h)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, &http.Request{URL: &url.URL{Path: "/"}})
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	last := o.get()
+	assert.Equal(t, "panic", last["level"])
+	assert.Equal(t, "panic: boom", last["message"])
+	assert.Equal(t, "bar", last["foo"])
+	if stack, ok := last["stack"].([]Frame); assert.True(t, ok) && assert.True(t, len(stack) > 1) {
+		assert.Contains(t, stack[0].Func, "TestRecoverHandler")
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, &http.Request{URL: &url.URL{Path: "/started"}})
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Equal(t, "panic: boom", o.get()["message"])
+
+	// The panicking function is reported as the caller, runtime errors included
+	h = RecoverHandler()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var p *int
+		*p = 1
+	}))
+	h = NewHandler(Config{Output: o, Caller: CallerShort})(h)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, &http.Request{URL: &url.URL{Path: "/"}})
+	last = o.get()
+	assert.Contains(t, last["file"], "handler_test.go:")
+	if stack, ok := last["stack"].([]Frame); assert.True(t, ok) && assert.True(t, len(stack) > 1) {
+		assert.Contains(t, stack[0].Func, "TestRecoverHandler")
+	}
+}
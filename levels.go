@@ -1,12 +1,21 @@
 package xlog
 
 import (
-	"bytes"
 	"fmt"
 	"strconv"
+	"strings"
+	"sync/atomic"
 )
 
 // Level defines log levels
+//
+// The values of the levels do not follow their severity: LevelNotice,
+// LevelCritical and LevelPanic were added after the other levels without
+// changing their values. Levels are ordered from the least to the most severe as
+// follows: Trace, Debug, Info, Notice, Warn, Error, Critical, Fatal and Panic.
+//
+// Levels must not be compared using their numeric values (i.e.: LevelNotice >
+// LevelError), use AtLeast or Severity instead.
 type Level int
 
 // Log levels
@@ -16,21 +25,71 @@ const (
 	LevelWarn
 	LevelError
 	LevelFatal
+	LevelNotice
+	LevelCritical
+	LevelPanic
+	LevelTrace Level = -1
 )
 
+// severities gives the rank of the levels from LevelTrace to LevelPanic in the
+// order of their values.
+var severities = [...]int{
+	LevelTrace + 1:    0,
+	LevelDebug + 1:    1,
+	LevelInfo + 1:     2,
+	LevelWarn + 1:     4,
+	LevelError + 1:    5,
+	LevelFatal + 1:    7,
+	LevelNotice + 1:   3,
+	LevelCritical + 1: 6,
+	LevelPanic + 1:    8,
+}
+
+// Severity returns the rank of l from the least to the most severe level, to be
+// used instead of l's value to compare levels. Unknown levels below LevelTrace
+// or above LevelPanic rank below and above all known levels respectively.
+func (l Level) Severity() int {
+	switch {
+	case l < LevelTrace:
+		return int(l - LevelTrace)
+	case l > LevelPanic:
+		return int(l) + 1
+	}
+	return severities[l+1]
+}
+
+// AtLeast returns true if l is as severe as min or more.
+func (l Level) AtLeast(min Level) bool {
+	return l.Severity() >= min.Severity()
+}
+
+// Leveler provides the minimum level a logger should output. Both Level and
+// *LevelVar implement this interface.
+type Leveler interface {
+	Level() Level
+}
+
 // Log level strings
 var (
-	levelDebug = "debug"
-	levelInfo  = "info"
-	levelWarn  = "warn"
-	levelError = "error"
-	levelFatal = "fatal"
-
-	levelBytesDebug = []byte(levelDebug)
-	levelBytesInfo  = []byte(levelInfo)
-	levelBytesWarn  = []byte(levelWarn)
-	levelBytesError = []byte(levelError)
-	levelBytesFatal = []byte(levelFatal)
+	levelTrace    = "trace"
+	levelDebug    = "debug"
+	levelInfo     = "info"
+	levelNotice   = "notice"
+	levelWarn     = "warn"
+	levelError    = "error"
+	levelCritical = "critical"
+	levelFatal    = "fatal"
+	levelPanic    = "panic"
+
+	levelBytesTrace    = []byte(levelTrace)
+	levelBytesDebug    = []byte(levelDebug)
+	levelBytesInfo     = []byte(levelInfo)
+	levelBytesNotice   = []byte(levelNotice)
+	levelBytesWarn     = []byte(levelWarn)
+	levelBytesError    = []byte(levelError)
+	levelBytesCritical = []byte(levelCritical)
+	levelBytesFatal    = []byte(levelFatal)
+	levelBytesPanic    = []byte(levelPanic)
 )
 
 // LevelFromString returns the level based on its string representation
@@ -40,38 +99,64 @@ func LevelFromString(t string) (Level, error) {
 	return l, err
 }
 
-// UnmarshalText lets Level implements the TextUnmarshaler interface used by encoding packages
+// UnmarshalText lets Level implements the TextUnmarshaler interface used by encoding packages.
+//
+// Level names are case insensitive and syslog severity names like "warning", "err",
+// "crit", "alert" or "emerg" are accepted as aliases.
 func (l *Level) UnmarshalText(text []byte) (err error) {
-	if bytes.Equal(text, levelBytesDebug) {
+	switch strings.ToLower(string(text)) {
+	case levelTrace:
+		*l = LevelTrace
+	case levelDebug:
 		*l = LevelDebug
-	} else if bytes.Equal(text, levelBytesInfo) {
+	case levelInfo, "informational":
 		*l = LevelInfo
-	} else if bytes.Equal(text, levelBytesWarn) {
+	case levelNotice:
+		*l = LevelNotice
+	case levelWarn, "warning":
 		*l = LevelWarn
-	} else if bytes.Equal(text, levelBytesError) {
+	case levelError, "err":
 		*l = LevelError
-	} else if bytes.Equal(text, levelBytesFatal) {
+	case levelCritical, "crit":
+		*l = LevelCritical
+	case levelFatal, "alert":
 		*l = LevelFatal
-	} else {
+	case levelPanic, "emerg", "emergency":
+		*l = LevelPanic
+	default:
 		err = fmt.Errorf("Uknown level %v", string(text))
 	}
 	return
 }
 
+// Level implements the Leveler interface so a Level can be used as a static
+// Config.Level.
+func (l Level) Level() Level {
+	return l
+}
+
 // String returns the string representation of the level.
 func (l Level) String() string {
 	var t string
 	switch l {
+	case LevelTrace:
+		t = levelTrace
 	case LevelDebug:
 		t = levelDebug
 	case LevelInfo:
 		t = levelInfo
+	case LevelNotice:
+		t = levelNotice
 	case LevelWarn:
 		t = levelWarn
 	case LevelError:
 		t = levelError
+	case LevelCritical:
+		t = levelCritical
 	case LevelFatal:
 		t = levelFatal
+	case LevelPanic:
+		t = levelPanic
 	default:
 		t = strconv.FormatInt(int64(l), 10)
 	}
@@ -82,18 +167,72 @@ func (l Level) String() string {
 func (l Level) MarshalText() ([]byte, error) {
 	var t []byte
 	switch l {
+	case LevelTrace:
+		t = levelBytesTrace
 	case LevelDebug:
 		t = levelBytesDebug
 	case LevelInfo:
 		t = levelBytesInfo
+	case LevelNotice:
+		t = levelBytesNotice
 	case LevelWarn:
 		t = levelBytesWarn
 	case LevelError:
 		t = levelBytesError
+	case LevelCritical:
+		t = levelBytesCritical
 	case LevelFatal:
 		t = levelBytesFatal
+	case LevelPanic:
+		t = levelBytesPanic
 	default:
 		t = []byte(strconv.FormatInt(int64(l), 10))
 	}
 	return t, nil
 }
+
+// LevelVar is a Level variable safe for concurrent use. Set a *LevelVar as
+// Config.Level to change the level of all loggers created from this config,
+// including the per request loggers created by NewHandler, while they are running.
+//
+// The zero value of LevelVar corresponds to LevelDebug.
+type LevelVar struct {
+	l int32
+}
+
+// NewLevelVar returns a new LevelVar initialized with the given level.
+func NewLevelVar(l Level) *LevelVar {
+	v := &LevelVar{}
+	v.SetLevel(l)
+	return v
+}
+
+// Level returns v's level.
+func (v *LevelVar) Level() Level {
+	return Level(atomic.LoadInt32(&v.l))
+}
+
+// SetLevel sets v's level to l.
+func (v *LevelVar) SetLevel(l Level) {
+	atomic.StoreInt32(&v.l, int32(l))
+}
+
+// String returns the string representation of v's level.
+func (v *LevelVar) String() string {
+	return v.Level().String()
+}
+
+// MarshalText lets LevelVar implements the TextMarshaler interface used by encoding packages
+func (v *LevelVar) MarshalText() ([]byte, error) {
+	return v.Level().MarshalText()
+}
+
+// UnmarshalText lets LevelVar implements the TextUnmarshaler interface used by encoding packages
+func (v *LevelVar) UnmarshalText(text []byte) error {
+	var l Level
+	if err := l.UnmarshalText(text); err != nil {
+		return err
+	}
+	v.SetLevel(l)
+	return nil
+}
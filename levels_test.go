@@ -22,6 +22,18 @@ func TestLevelFromString(t *testing.T) {
 	l, err = LevelFromString("fatal")
 	assert.NoError(t, err)
 	assert.Equal(t, LevelFatal, l)
+	l, err = LevelFromString("trace")
+	assert.NoError(t, err)
+	assert.Equal(t, LevelTrace, l)
+	l, err = LevelFromString("notice")
+	assert.NoError(t, err)
+	assert.Equal(t, LevelNotice, l)
+	l, err = LevelFromString("critical")
+	assert.NoError(t, err)
+	assert.Equal(t, LevelCritical, l)
+	l, err = LevelFromString("panic")
+	assert.NoError(t, err)
+	assert.Equal(t, LevelPanic, l)
 	_, err = LevelFromString("foo")
 	assert.Error(t, err, "")
 }
@@ -46,12 +58,60 @@ func TestLevelUnmarshalerText(t *testing.T) {
 	assert.Error(t, l.UnmarshalText([]byte("invalid")))
 }
 
+func TestLevelUnmarshalerTextAliases(t *testing.T) {
+	aliases := map[string]Level{
+		"TRACE":         LevelTrace,
+		"Debug":         LevelDebug,
+		"INFO":          LevelInfo,
+		"informational": LevelInfo,
+		"NOTICE":        LevelNotice,
+		"WARNING":       LevelWarn,
+		"Warn":          LevelWarn,
+		"err":           LevelError,
+		"ERROR":         LevelError,
+		"crit":          LevelCritical,
+		"Critical":      LevelCritical,
+		"alert":         LevelFatal,
+		"FATAL":         LevelFatal,
+		"emerg":         LevelPanic,
+		"Emergency":     LevelPanic,
+		"PANIC":         LevelPanic,
+	}
+	for text, want := range aliases {
+		l := Level(-10)
+		if assert.NoError(t, l.UnmarshalText([]byte(text)), text) {
+			assert.Equal(t, want, l, text)
+		}
+	}
+}
+
+func TestLevelOrder(t *testing.T) {
+	levels := []Level{LevelTrace, LevelDebug, LevelInfo, LevelNotice, LevelWarn, LevelError, LevelCritical, LevelFatal, LevelPanic}
+	for i := 1; i < len(levels); i++ {
+		assert.True(t, levels[i-1].Severity() < levels[i].Severity(), levels[i].String())
+		assert.True(t, levels[i].AtLeast(levels[i-1]), levels[i].String())
+		assert.False(t, levels[i-1].AtLeast(levels[i]), levels[i].String())
+		assert.True(t, levels[i].AtLeast(levels[i]), levels[i].String())
+	}
+	assert.True(t, Level(-2).Severity() < LevelTrace.Severity())
+	assert.True(t, Level(8).Severity() > LevelPanic.Severity())
+	assert.Equal(t, LevelDebug, Level(0))
+	assert.Equal(t, LevelInfo, Level(1))
+	assert.Equal(t, LevelWarn, Level(2))
+	assert.Equal(t, LevelError, Level(3))
+	assert.Equal(t, LevelFatal, Level(4))
+}
+
 func TestLevelString(t *testing.T) {
 	assert.Equal(t, "debug", LevelDebug.String())
 	assert.Equal(t, "info", LevelInfo.String())
 	assert.Equal(t, "warn", LevelWarn.String())
 	assert.Equal(t, "error", LevelError.String())
 	assert.Equal(t, "fatal", LevelFatal.String())
+	assert.Equal(t, "trace", LevelTrace.String())
+	assert.Equal(t, "notice", LevelNotice.String())
+	assert.Equal(t, "critical", LevelCritical.String())
+	assert.Equal(t, "panic", LevelPanic.String())
 	assert.Equal(t, "10", Level(10).String())
 }
 
@@ -71,7 +131,29 @@ func TestLevelMarshalerText(t *testing.T) {
 	b, err = LevelFatal.MarshalText()
 	assert.NoError(t, err)
 	assert.Equal(t, string(levelBytesFatal), string(b))
+	for _, l := range []Level{LevelTrace, LevelNotice, LevelCritical, LevelPanic} {
+		b, err = l.MarshalText()
+		assert.NoError(t, err)
+		assert.Equal(t, l.String(), string(b))
+	}
 	b, err = Level(10).MarshalText()
 	assert.NoError(t, err)
 	assert.Equal(t, "10", string(b))
 }
+
+func TestLevelVar(t *testing.T) {
+	v := &LevelVar{}
+	assert.Equal(t, LevelDebug, v.Level())
+	v.SetLevel(LevelWarn)
+	assert.Equal(t, LevelWarn, v.Level())
+	assert.Equal(t, "warn", v.String())
+	v = NewLevelVar(LevelError)
+	assert.Equal(t, LevelError, v.Level())
+	b, err := v.MarshalText()
+	assert.NoError(t, err)
+	assert.Equal(t, "error", string(b))
+	assert.NoError(t, v.UnmarshalText([]byte("info")))
+	assert.Equal(t, LevelInfo, v.Level())
+	assert.Error(t, v.UnmarshalText([]byte("invalid")))
+	assert.Equal(t, LevelInfo, v.Level())
+}
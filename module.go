@@ -0,0 +1,23 @@
+// +build go1.12
+
+package xlog
+
+import (
+	"runtime/debug"
+	"strings"
+)
+
+// mainModule returns the path of the main module and the import path of the
+// main package, empty if unknown.
+func mainModule() (mod, mainPkg string) {
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "", ""
+	}
+	mainPkg = strings.TrimSuffix(bi.Path, ".test")
+	if mainPkg == "command-line-arguments" {
+		// Built from a list of files
+		mainPkg = ""
+	}
+	return bi.Main.Path, mainPkg
+}
@@ -0,0 +1,9 @@
+// +build !go1.12
+
+package xlog
+
+// mainModule returns the path of the main module and the import path of the
+// main package, empty as build information is not available before Go 1.12.
+func mainModule() (mod, mainPkg string) {
+	return "", ""
+}
@@ -1,5 +1,7 @@
 package xlog
 
+import "fmt"
+
 type nop struct{}
 
 // NopLogger is an no-op implementation of xlog.Logger
@@ -9,8 +11,16 @@ func (n nop) SetField(name string, value interface{}) {}
 
 func (n nop) GetFields() F { return map[string]interface{}{} }
 
+func (n nop) With(fields F) Logger { return NopLogger }
+
+func (n nop) Enabled(level Level) bool { return false }
+
 func (n nop) OutputF(level Level, calldepth int, msg string, fields map[string]interface{}) {}
 
+func (n nop) Trace(v ...interface{}) {}
+
+func (n nop) Tracef(format string, v ...interface{}) {}
+
 func (n nop) Debug(v ...interface{}) {}
 
 func (n nop) Debugf(format string, v ...interface{}) {}
@@ -19,6 +29,10 @@ func (n nop) Info(v ...interface{}) {}
 
 func (n nop) Infof(format string, v ...interface{}) {}
 
+func (n nop) Notice(v ...interface{}) {}
+
+func (n nop) Noticef(format string, v ...interface{}) {}
+
 func (n nop) Warn(v ...interface{}) {}
 
 func (n nop) Warnf(format string, v ...interface{}) {}
@@ -27,6 +41,10 @@ func (n nop) Error(v ...interface{}) {}
 
 func (n nop) Errorf(format string, v ...interface{}) {}
 
+func (n nop) Critical(v ...interface{}) {}
+
+func (n nop) Criticalf(format string, v ...interface{}) {}
+
 func (n nop) Fatal(v ...interface{}) {
 	exit1()
 }
@@ -35,6 +53,16 @@ func (n nop) Fatalf(format string, v ...interface{}) {
 	exit1()
 }
 
+func (n nop) Panic(v ...interface{}) {
+	extractFields(&v)
+	panic(fmt.Sprint(v...))
+}
+
+func (n nop) Panicf(format string, v ...interface{}) {
+	extractFields(&v)
+	panic(fmt.Sprintf(format, v...))
+}
+
 func (n nop) Write(p []byte) (int, error) { return len(p), nil }
 
 func (n nop) Output(calldepth int, s string) error {
@@ -1,22 +1,36 @@
 package xlog
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
 
 func TestNopLogger(t *testing.T) {
 	// cheap cover score upper
 	NopLogger.SetField("name", "value")
+	NopLogger.With(F{"name": "value"})
+	assert.False(t, NopLogger.Enabled(LevelPanic))
 	NopLogger.OutputF(LevelInfo, 0, "", nil)
+	NopLogger.Trace()
+	NopLogger.Tracef("format")
 	NopLogger.Debug()
 	NopLogger.Debugf("format")
 	NopLogger.Info()
 	NopLogger.Infof("format")
+	NopLogger.Notice()
+	NopLogger.Noticef("format")
 	NopLogger.Warn()
 	NopLogger.Warnf("format")
 	NopLogger.Error()
 	NopLogger.Errorf("format")
+	NopLogger.Critical()
+	NopLogger.Criticalf("format")
 	exit1 = func() {}
 	NopLogger.Fatal()
 	NopLogger.Fatalf("format")
 	NopLogger.Write([]byte{})
+	assert.PanicsWithValue(t, "message", func() { NopLogger.Panic("message") })
+	assert.PanicsWithValue(t, "format", func() { NopLogger.Panicf("format") })
 	NopLogger.Output(0, "")
 }
@@ -7,6 +7,7 @@ import (
 	"io"
 	"os"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -29,43 +30,237 @@ func (of OutputFunc) Write(fields map[string]interface{}) error {
 	return of(fields)
 }
 
+// BatchOutput sends several log messages at once to a destination, like a
+// network service for which sending a message per request would be inefficient.
+// An OutputChannel configured with a BatchSize calls WriteBatch with up to
+// BatchSize messages if its output implements BatchOutput.
+type BatchOutput interface {
+	WriteBatch(msgs []map[string]interface{}) error
+}
+
+// BatchOutputFunc is an adapter to allow the use of ordinary functions as
+// BatchOutput handlers. It also implements Output, messages written with Write
+// being sent as a batch of one so it can be given to an OutputChannel.
+type BatchOutputFunc func(msgs []map[string]interface{}) error
+
+func (bf BatchOutputFunc) WriteBatch(msgs []map[string]interface{}) error {
+	return bf(msgs)
+}
+
+func (bf BatchOutputFunc) Write(fields map[string]interface{}) error {
+	return bf([]map[string]interface{}{fields})
+}
+
+// NewBatchOutput returns o as a BatchOutput. If o does not implement BatchOutput,
+// the messages of a batch are written one by one with o's Write method, the last
+// error being returned.
+func NewBatchOutput(o Output) BatchOutput {
+	if b, ok := o.(BatchOutput); ok {
+		return b
+	}
+	return batchOutput{o}
+}
+
+type batchOutput struct {
+	Output
+}
+
+func (b batchOutput) WriteBatch(msgs []map[string]interface{}) (err error) {
+	for _, msg := range msgs {
+		if e := b.Write(msg); e != nil {
+			err = e
+		}
+	}
+	return
+}
+
 // OutputChannel is a send buffered channel between xlog and an Output.
 type OutputChannel struct {
-	input  chan map[string]interface{}
 	output Output
-	stop   chan struct{}
+	batch  BatchOutput
+	opts   OutputChannelOptions
+	// stop is closed to stop the consumer go routine, which closes done once the
+	// queue is drained
+	stop chan struct{}
+	done chan struct{}
+
+	mu     sync.Mutex
+	closed bool
+	queue  messageQueue
+	// queued is the time the batch being filled was started
+	queued time.Time
+	// wake notifies the consumer go routine of new messages
+	wake chan struct{}
+	// room is closed to notify writers blocked by the Block policy when messages
+	// are dequeued
+	room chan struct{}
+
+	// dropped counts the messages dropped since droppedSince, nil if none
+	dropped      *levelCounts
+	droppedSince time.Time
+	lastReport   time.Time
+
+	droppedTotal uint64
+
+	// wmu serializes the writes to output
+	wmu sync.Mutex
+
+	// smu protects the write statistics
+	smu       sync.Mutex
+	written   uint64
+	errors    uint64
+	writeTime time.Duration
+	latencies [latencyWindow]time.Duration
+	nWrites   uint64
 }
 
+// DropPolicy defines what an OutputChannel does with a new message when its
+// buffer is full.
+type DropPolicy int
+
+// Drop policies
+const (
+	// DropNewest discards the new message, Write returning ErrBufferFull.
+	DropNewest DropPolicy = iota
+	// DropOldest discards the oldest queued message to make room for the new
+	// one.
+	DropOldest
+	// DropLowestLevel discards the oldest of the queued messages with the lowest
+	// level if it is lower than the new message's level, so warnings and errors
+	// are not lost to a burst of debug messages. Otherwise the new message is
+	// discarded as with DropNewest.
+	DropLowestLevel
+	// Block waits for room in the buffer for up to BlockTimeout before
+	// discarding the new message as with DropNewest.
+	Block
+)
+
+// OutputChannelOptions configures an OutputChannel.
+type OutputChannelOptions struct {
+	// BufferSize is the maximum number of messages queued. Defaults to 100 if 0
+	// or less.
+	BufferSize int
+	// DropPolicy defines what happens when a message is written while the
+	// buffer is full. Defaults to DropNewest.
+	DropPolicy DropPolicy
+	// BlockTimeout is the maximum duration Write waits for room in the buffer
+	// with the Block policy. Write waits indefinitely if 0.
+	BlockTimeout time.Duration
+	// ReportInterval is the minimum duration between two loss reports. When
+	// messages are dropped, the channel outputs a warning with the number of
+	// messages dropped per level once there is room again. Defaults to 10
+	// seconds.
+	ReportInterval time.Duration
+	// BatchSize is the maximum number of messages written at once to the
+	// output, limited to BufferSize. Messages are written one by one if 0 or 1.
+	// Outputs not implementing BatchOutput get the messages of a batch one by
+	// one.
+	BatchSize int
+	// BatchInterval is the maximum duration a message waits for a batch to be
+	// filled before being written. Defaults to 1 second.
+	BatchInterval time.Duration
+}
+
+// Fields of the loss reports
+const (
+	dropReportMessage = "messages dropped"
+	dropReportSince   = "since"
+	dropReportLevels  = "levels"
+)
+
 // ErrBufferFull is returned when the output channel buffer is full and messages
 // are discarded.
 var ErrBufferFull = errors.New("buffer full")
 
+// ErrClosed is returned when a message is written to a closed output channel.
+var ErrClosed = errors.New("output channel closed")
+
 // NewOutputChannel creates a consumer buffered channel for the given output
 // with a default buffer of 100 messages.
 func NewOutputChannel(o Output) *OutputChannel {
-	return NewOutputChannelBuffer(o, 100)
+	return NewOutputChannelWithOptions(o, OutputChannelOptions{})
 }
 
 // NewOutputChannelBuffer creates a consumer buffered channel for the given output
-// with a customizable buffer size.
+// with a customizable buffer size. A size of 0 or less selects the default
+// buffer of 100 messages.
 func NewOutputChannelBuffer(o Output, bufSize int) *OutputChannel {
+	return NewOutputChannelWithOptions(o, OutputChannelOptions{BufferSize: bufSize})
+}
+
+// NewOutputChannelWithOptions creates a consumer buffered channel for the given
+// output with the given options.
+func NewOutputChannelWithOptions(o Output, opts OutputChannelOptions) *OutputChannel {
+	if opts.BufferSize <= 0 {
+		opts.BufferSize = 100
+	}
+	if opts.ReportInterval == 0 {
+		opts.ReportInterval = 10 * time.Second
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 1
+	} else if opts.BatchSize > opts.BufferSize {
+		// A larger batch could never be filled
+		opts.BatchSize = opts.BufferSize
+	}
+	if opts.BatchInterval == 0 {
+		opts.BatchInterval = time.Second
+	}
 	oc := &OutputChannel{
-		input:  make(chan map[string]interface{}, bufSize),
 		output: o,
+		batch:  NewBatchOutput(o),
+		opts:   opts,
 		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+		queue:  newMessageQueue(opts.BufferSize),
+		wake:   make(chan struct{}, 1),
+		room:   make(chan struct{}),
 	}
 
 	go func() {
+		defer close(oc.done)
 		for {
 			select {
-			case msg := <-oc.input:
-				if err := o.Write(msg); err != nil {
-					critialLogger.Print("cannot write log message: ", err.Error())
+			case <-oc.stop:
+				oc.drain()
+				return
+			default:
+			}
+			msgs, batchWait := oc.pop(false)
+			if msgs != nil {
+				oc.write(msgs...)
+				if r, _ := oc.dropReport(false); r != nil {
+					oc.write(r)
 				}
+				continue
+			}
+			r, wait := oc.dropReport(false)
+			if r != nil {
+				oc.write(r)
+				continue
+			}
+			if batchWait > 0 && (wait == 0 || batchWait < wait) {
+				wait = batchWait
+			}
+			var timeout <-chan time.Time
+			var t *time.Timer
+			if wait > 0 {
+				t = time.NewTimer(wait)
+				timeout = t.C
+			}
+			select {
+			case <-oc.wake:
+			case <-timeout:
 			case <-oc.stop:
-				close(oc.stop)
+				if t != nil {
+					t.Stop()
+				}
+				oc.drain()
 				return
 			}
+			if t != nil {
+				t.Stop()
+			}
 		}
 	}()
 
@@ -73,40 +268,243 @@ func NewOutputChannelBuffer(o Output, bufSize int) *OutputChannel {
 }
 
 // Write implements the Output interface
-func (oc *OutputChannel) Write(fields map[string]interface{}) (err error) {
+func (oc *OutputChannel) Write(fields map[string]interface{}) error {
+	oc.mu.Lock()
+	full := !oc.closed && oc.queue.len() >= oc.opts.BufferSize && !oc.makeRoom(fields)
+	// makeRoom releases the lock while waiting for room with the Block policy so
+	// the channel may have been closed meanwhile
+	if oc.closed {
+		oc.mu.Unlock()
+		return ErrClosed
+	}
+	if full {
+		oc.drop(fields)
+		oc.mu.Unlock()
+		// Channel is full, message dropped
+		return ErrBufferFull
+	}
+	if oc.queue.len() == 0 && oc.opts.BatchSize > 1 {
+		oc.queued = time.Now()
+	}
+	oc.queue.push(fields)
+	oc.mu.Unlock()
 	select {
-	case oc.input <- fields:
-		// Sent with success
+	case oc.wake <- struct{}{}:
 	default:
-		// Channel is full, message dropped
-		err = ErrBufferFull
 	}
-	return err
+	return nil
 }
 
-// Flush flushes all the buffered message to the output
+// makeRoom applies the drop policy to make room in the queue for the message
+// fields. It returns false if the message must be discarded or if the channel
+// got closed while waiting for room. It must be called with oc.mu held.
+func (oc *OutputChannel) makeRoom(fields map[string]interface{}) bool {
+	switch oc.opts.DropPolicy {
+	case DropOldest:
+		oc.drop(oc.queue.at(0))
+		oc.queue.remove(0)
+		return true
+	case DropLowestLevel:
+		level := messageLevel(fields)
+		i := -1
+		for j := 0; j < oc.queue.len(); j++ {
+			if l := messageLevel(oc.queue.at(j)); !l.AtLeast(level) {
+				i, level = j, l
+			}
+		}
+		if i == -1 {
+			return false
+		}
+		oc.drop(oc.queue.at(i))
+		oc.queue.remove(i)
+		return true
+	case Block:
+		var timeout <-chan time.Time
+		if oc.opts.BlockTimeout > 0 {
+			t := time.NewTimer(oc.opts.BlockTimeout)
+			defer t.Stop()
+			timeout = t.C
+		}
+		for oc.queue.len() >= oc.opts.BufferSize {
+			if oc.closed {
+				return false
+			}
+			room := oc.room
+			oc.mu.Unlock()
+			select {
+			case <-room:
+			case <-timeout:
+				oc.mu.Lock()
+				return false
+			}
+			oc.mu.Lock()
+		}
+		return true
+	}
+	return false
+}
+
+// drop counts a dropped message. It must be called with oc.mu held.
+func (oc *OutputChannel) drop(fields map[string]interface{}) {
+	if oc.dropped == nil {
+		oc.dropped = &levelCounts{}
+		oc.droppedSince = now()
+	}
+	oc.dropped.inc(messageLevel(fields))
+	oc.droppedTotal++
+}
+
+// dropReport returns the loss report of the messages dropped since the previous
+// report if any. Unless force is true, the report is only returned once
+// ReportInterval has elapsed since the previous report, wait being the
+// remaining time otherwise.
+func (oc *OutputChannel) dropReport(force bool) (msg map[string]interface{}, wait time.Duration) {
+	oc.mu.Lock()
+	defer oc.mu.Unlock()
+	if oc.dropped == nil {
+		return nil, 0
+	}
+	t := now()
+	if elapsed := t.Sub(oc.lastReport); !force && !oc.lastReport.IsZero() && elapsed < oc.opts.ReportInterval {
+		return nil, oc.opts.ReportInterval - elapsed
+	}
+	msg = map[string]interface{}{
+		KeyTime:          t,
+		KeyLevel:         LevelWarn.String(),
+		KeyMessage:       dropReportMessage,
+		KeyDropped:       oc.dropped.total(),
+		dropReportLevels: oc.dropped.LogValue(),
+		dropReportSince:  oc.droppedSince,
+	}
+	oc.dropped = nil
+	oc.lastReport = t
+	return msg, 0
+}
+
+// pop dequeues a batch of up to BatchSize of the oldest messages, if any. Unless
+// force is true, a partial batch is only dequeued once its oldest message has
+// waited for BatchInterval, wait being the remaining time otherwise.
+func (oc *OutputChannel) pop(force bool) (msgs []map[string]interface{}, wait time.Duration) {
+	oc.mu.Lock()
+	defer oc.mu.Unlock()
+	n := oc.queue.len()
+	if n == 0 {
+		return nil, 0
+	}
+	if n > oc.opts.BatchSize {
+		n = oc.opts.BatchSize
+	}
+	if !force && n < oc.opts.BatchSize {
+		if elapsed := time.Since(oc.queued); elapsed < oc.opts.BatchInterval {
+			return nil, oc.opts.BatchInterval - elapsed
+		}
+	}
+	msgs = oc.queue.pop(n)
+	if oc.opts.BatchSize > 1 && oc.queue.len() > 0 {
+		// The remaining messages start a new batch
+		oc.queued = time.Now()
+	}
+	if oc.opts.DropPolicy == Block {
+		close(oc.room)
+		oc.room = make(chan struct{})
+	}
+	return msgs, 0
+}
+
+// write writes a batch of messages to the output.
+func (oc *OutputChannel) write(msgs ...map[string]interface{}) {
+	oc.wmu.Lock()
+	defer oc.wmu.Unlock()
+	start := time.Now()
+	err := oc.batch.WriteBatch(msgs)
+	d := time.Since(start)
+	oc.smu.Lock()
+	oc.writeTime += d
+	oc.latencies[oc.nWrites%latencyWindow] = d
+	oc.nWrites++
+	if err != nil {
+		oc.errors += uint64(len(msgs))
+	} else {
+		oc.written += uint64(len(msgs))
+	}
+	oc.smu.Unlock()
+	if err != nil {
+		critialLogger.Print("cannot write log message: ", err.Error())
+	}
+}
+
+// messageLevel returns the level of a message, LevelInfo if it has none.
+func messageLevel(fields map[string]interface{}) Level {
+	if s, ok := fields[KeyLevel].(string); ok {
+		if l, err := LevelFromString(s); err == nil {
+			return l
+		}
+	}
+	return LevelInfo
+}
+
+// flusher is implemented by outputs buffering messages, like DedupOutput.
+type flusher interface {
+	Flush()
+}
+
+// Flush flushes all the buffered message to the output, followed by the report
+// of the messages dropped if any and ReportInterval has elapsed since the
+// previous report.
 func (oc *OutputChannel) Flush() {
+	oc.flush(false)
+}
+
+// flush writes the queued messages to the output, followed by the report of the
+// messages dropped, even before ReportInterval has elapsed if force is true.
+func (oc *OutputChannel) flush(force bool) {
 	for {
-		select {
-		case msg := <-oc.input:
-			if err := oc.output.Write(msg); err != nil {
-				critialLogger.Print("cannot write log message: ", err.Error())
-			}
-		default:
-			return
+		msgs, _ := oc.pop(true)
+		if msgs == nil {
+			break
 		}
+		oc.write(msgs...)
+	}
+	if r, _ := oc.dropReport(force); r != nil {
+		oc.write(r)
+	}
+}
+
+// drain writes the queued messages to the output before the consumer's go
+// routine exits. If the output buffers messages itself, like DedupOutput, it is
+// flushed too.
+func (oc *OutputChannel) drain() {
+	oc.flush(true)
+	if f, ok := oc.output.(flusher); ok {
+		f.Flush()
 	}
 }
 
-// Close closes the output channel and release the consumer's go routine.
+// Close closes the output channel and waits for the queued messages to be
+// written to the output. It is equivalent to Shutdown without deadline.
 func (oc *OutputChannel) Close() {
-	if oc.stop == nil {
-		return
+	oc.shutdown(nil)
+}
+
+// shutdown closes the channel and waits for the queue to be drained. It returns
+// false if cancel is closed before. The channel is closed on the first call,
+// the following calls only wait.
+func (oc *OutputChannel) shutdown(cancel <-chan struct{}) bool {
+	oc.mu.Lock()
+	if !oc.closed {
+		oc.closed = true
+		close(oc.stop)
+		// Release the writers blocked by the Block policy
+		close(oc.room)
+		oc.room = make(chan struct{})
+	}
+	oc.mu.Unlock()
+	select {
+	case <-oc.done:
+		return true
+	case <-cancel:
+		return false
 	}
-	oc.stop <- struct{}{}
-	<-oc.stop
-	oc.stop = nil
-	oc.Flush()
 }
 
 // Discard is an Output that discards all log message going thru it.
@@ -153,26 +551,38 @@ func (f FilterOutput) Write(fields map[string]interface{}) (err error) {
 
 // LevelOutput routes messages to different output based on the message's level.
 type LevelOutput struct {
-	Debug Output
-	Info  Output
-	Warn  Output
-	Error Output
-	Fatal Output
+	Trace    Output
+	Debug    Output
+	Info     Output
+	Notice   Output
+	Warn     Output
+	Error    Output
+	Critical Output
+	Fatal    Output
+	Panic    Output
 }
 
 func (l LevelOutput) Write(fields map[string]interface{}) error {
 	var o Output
 	switch fields[KeyLevel] {
+	case "trace":
+		o = l.Trace
 	case "debug":
 		o = l.Debug
 	case "info":
 		o = l.Info
+	case "notice":
+		o = l.Notice
 	case "warn":
 		o = l.Warn
 	case "error":
 		o = l.Error
+	case "critical":
+		o = l.Critical
 	case "fatal":
 		o = l.Fatal
+	case "panic":
+		o = l.Panic
 	}
 	if o != nil {
 		return o.Write(fields)
@@ -226,17 +636,20 @@ func (o consoleOutput) Write(fields map[string]interface{}) error {
 		buf.Reset()
 		bufPool.Put(buf)
 	}()
+	fields = resolveFields(fields, true)
 	if ts, ok := fields[KeyTime].(time.Time); ok {
 		buf.Write([]byte(ts.Format("2006/01/02 15:04:05 ")))
 	}
 	if lvl, ok := fields[KeyLevel].(string); ok {
 		levelColor := blue
 		switch lvl {
-		case "debug":
+		case "trace", "debug":
 			levelColor = gray
+		case "notice":
+			levelColor = cyan
 		case "warn":
 			levelColor = yellow
-		case "error":
+		case "error", "critical", "fatal", "panic":
 			levelColor = red
 		}
 		colorPrint(buf, strings.ToUpper(lvl[0:4]), levelColor)
@@ -248,10 +661,15 @@ func (o consoleOutput) Write(fields map[string]interface{}) error {
 	}
 	// Gather field keys
 	keys := []string{}
+	stack, hasStack := fields[KeyStack].([]Frame)
 	for k := range fields {
 		switch k {
 		case KeyLevel, KeyMessage, KeyTime:
 			continue
+		case KeyStack:
+			if hasStack {
+				continue
+			}
 		}
 		keys = append(keys, k)
 	}
@@ -267,6 +685,16 @@ func (o consoleOutput) Write(fields map[string]interface{}) error {
 		}
 	}
 	buf.WriteByte('\n')
+	// Print the stack trace as an indented block
+	for _, f := range stack {
+		buf.WriteString("    ")
+		buf.WriteString(f.Func)
+		buf.WriteString("\n        ")
+		buf.WriteString(f.File)
+		buf.WriteByte(':')
+		buf.WriteString(strconv.FormatInt(int64(f.Line), 10))
+		buf.WriteByte('\n')
+	}
 	_, err := o.w.Write(buf.Bytes())
 	return err
 }
@@ -286,6 +714,7 @@ func (o logfmtOutput) Write(fields map[string]interface{}) error {
 		buf.Reset()
 		bufPool.Put(buf)
 	}()
+	fields = resolveFields(fields, true)
 	// Gather field keys
 	keys := []string{}
 	for k := range fields {
@@ -320,7 +749,7 @@ func (o logfmtOutput) Write(fields map[string]interface{}) error {
 func NewJSONOutput(w io.Writer) Output {
 	enc := json.NewEncoder(w)
 	return OutputFunc(func(fields map[string]interface{}) error {
-		return enc.Encode(fields)
+		return enc.Encode(resolveFields(fields, false))
 	})
 }
 
@@ -330,7 +759,7 @@ func NewLogstashOutput(w io.Writer) Output {
 		lsf := map[string]interface{}{
 			"@version": 1,
 		}
-		for k, v := range fields {
+		for k, v := range resolveFields(fields, false) {
 			switch k {
 			case KeyTime:
 				k = "@timestamp"
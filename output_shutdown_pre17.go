@@ -0,0 +1,17 @@
+// +build !go1.7
+
+package xlog
+
+import "golang.org/x/net/context"
+
+// Shutdown closes the output channel and waits for the queued messages to be
+// written to the output, or for ctx to be done in which case ctx's error is
+// returned. Messages written after Shutdown is called are rejected with
+// ErrClosed. It is safe to call Shutdown concurrently and more than once, all
+// calls waiting for the queue to be drained.
+func (oc *OutputChannel) Shutdown(ctx context.Context) error {
+	if !oc.shutdown(ctx.Done()) {
+		return ctx.Err()
+	}
+	return nil
+}
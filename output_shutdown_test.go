@@ -0,0 +1,36 @@
+// +build go1.7
+
+package xlog
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutputChannelShutdown(t *testing.T) {
+	o := newTestOutput()
+	oc := NewOutputChannel(o)
+	assert.NoError(t, oc.Write(F{"message": "a"}))
+	assert.NoError(t, oc.Shutdown(context.Background()))
+	assert.Equal(t, "a", o.get()["message"])
+	assert.Equal(t, ErrClosed, oc.Write(F{"message": "b"}))
+	assert.NoError(t, oc.Shutdown(context.Background()))
+	assert.True(t, o.empty())
+}
+
+func TestOutputChannelShutdownDeadline(t *testing.T) {
+	oc, o, release := newBlockedOutputChannel(OutputChannelOptions{})
+	assert.NoError(t, oc.Write(F{"message": "a"}))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, oc.Shutdown(ctx))
+	assert.Equal(t, ErrClosed, oc.Write(F{"message": "b"}))
+	close(release)
+	assert.NoError(t, oc.Shutdown(context.Background()))
+	assert.Equal(t, "first", o.get()["message"])
+	assert.Equal(t, "a", o.get()["message"])
+	assert.True(t, o.empty())
+}
@@ -18,11 +18,17 @@ func NewSyslogOutput(network, address, tag string) Output {
 // with the proper priority added to the passed facility.
 // If network and address are empty, Dial will connect to the local syslog server.
 func NewSyslogOutputFacility(network, address, tag string, facility syslog.Priority) Output {
+	debug := NewJSONOutput(NewSyslogWriter(network, address, facility|syslog.LOG_DEBUG, tag))
 	o := LevelOutput{
-		Debug: NewJSONOutput(NewSyslogWriter(network, address, facility|syslog.LOG_DEBUG, tag)),
-		Info:  NewJSONOutput(NewSyslogWriter(network, address, facility|syslog.LOG_INFO, tag)),
-		Warn:  NewJSONOutput(NewSyslogWriter(network, address, facility|syslog.LOG_WARNING, tag)),
-		Error: NewJSONOutput(NewSyslogWriter(network, address, facility|syslog.LOG_ERR, tag)),
+		Trace:    debug,
+		Debug:    debug,
+		Info:     NewJSONOutput(NewSyslogWriter(network, address, facility|syslog.LOG_INFO, tag)),
+		Notice:   NewJSONOutput(NewSyslogWriter(network, address, facility|syslog.LOG_NOTICE, tag)),
+		Warn:     NewJSONOutput(NewSyslogWriter(network, address, facility|syslog.LOG_WARNING, tag)),
+		Error:    NewJSONOutput(NewSyslogWriter(network, address, facility|syslog.LOG_ERR, tag)),
+		Critical: NewJSONOutput(NewSyslogWriter(network, address, facility|syslog.LOG_CRIT, tag)),
+		Fatal:    NewJSONOutput(NewSyslogWriter(network, address, facility|syslog.LOG_ALERT, tag)),
+		Panic:    NewJSONOutput(NewSyslogWriter(network, address, facility|syslog.LOG_EMERG, tag)),
 	}
 	return o
 }
@@ -7,6 +7,7 @@ import (
 	"io/ioutil"
 	"log"
 	"os"
+	"runtime"
 	"testing"
 	"time"
 
@@ -57,7 +58,7 @@ func TestOutputChannel(t *testing.T) {
 	o := newTestOutput()
 	oc := NewOutputChannel(o)
 	defer oc.Close()
-	oc.input <- F{"foo": "bar"}
+	oc.Write(F{"foo": "bar"})
 	assert.Equal(t, F{"foo": "bar"}, F(o.get()))
 }
 
@@ -71,7 +72,7 @@ func TestOutputChannelError(t *testing.T) {
 		critialLogger = log.New(w, "", 0)
 		o := newTestOutputErr(errors.New("some error"))
 		oc := NewOutputChannel(o)
-		oc.input <- F{"foo": "bar"}
+		oc.Write(F{"foo": "bar"})
 		o.get()
 		oc.Close()
 		critialLogger = oldCritialLogger
@@ -83,12 +84,327 @@ func TestOutputChannelError(t *testing.T) {
 }
 
 func TestOutputChannelClose(t *testing.T) {
-	oc := NewOutputChannel(newTestOutput())
+	o := newTestOutput()
+	oc := NewOutputChannel(o)
 	defer oc.Close()
-	assert.NotNil(t, oc.stop)
+	assert.NoError(t, oc.Write(F{"message": "a"}))
 	oc.Close()
-	assert.Nil(t, oc.stop)
+	assert.True(t, oc.closed)
+	assert.Equal(t, "a", o.get()["message"])
+	assert.Equal(t, ErrClosed, oc.Write(F{"message": "b"}))
 	oc.Close()
+	assert.True(t, o.empty())
+}
+
+func TestOutputChannelCloseConcurrent(t *testing.T) {
+	oc, o, release := newBlockedOutputChannel(OutputChannelOptions{})
+	assert.NoError(t, oc.Write(F{"message": "a"}))
+	done := make(chan struct{})
+	for i := 0; i < 2; i++ {
+		go func() {
+			oc.Close()
+			done <- struct{}{}
+		}()
+	}
+	close(release)
+	<-done
+	<-done
+	assert.Equal(t, "first", o.get()["message"])
+	assert.Equal(t, "a", o.get()["message"])
+	assert.True(t, o.empty())
+}
+
+func TestOutputChannelCloseBlockedWriter(t *testing.T) {
+	oc, o, release := newBlockedOutputChannel(OutputChannelOptions{DropPolicy: Block})
+	assert.NoError(t, oc.Write(F{"message": "a"}))
+	assert.NoError(t, oc.Write(F{"message": "b"}))
+	done := make(chan error)
+	go func() {
+		done <- oc.Write(F{"message": "c"})
+	}()
+	closed := make(chan struct{})
+	go func() {
+		oc.Close()
+		close(closed)
+	}()
+	assert.Equal(t, ErrClosed, <-done)
+	close(release)
+	<-closed
+	assert.Equal(t, "first", o.get()["message"])
+	assert.Equal(t, "a", o.get()["message"])
+	assert.Equal(t, "b", o.get()["message"])
+	assert.True(t, o.empty())
+}
+
+func TestOutputChannelCloseBlockedWriterRoom(t *testing.T) {
+	oc, o, release := newBlockedOutputChannel(OutputChannelOptions{DropPolicy: Block})
+	assert.NoError(t, oc.Write(F{"message": "a"}))
+	assert.NoError(t, oc.Write(F{"message": "b"}))
+	done := make(chan error)
+	go func() {
+		done <- oc.Write(F{"message": "c"})
+	}()
+	// Let the writer wait for room
+	time.Sleep(10 * time.Millisecond)
+	// The consumer frees room as the channel is closed
+	oc.mu.Lock()
+	oc.queue.pop(1)
+	oc.mu.Unlock()
+	closed := make(chan struct{})
+	go func() {
+		oc.Close()
+		close(closed)
+	}()
+	assert.Equal(t, ErrClosed, <-done)
+	close(release)
+	<-closed
+	assert.Equal(t, "first", o.get()["message"])
+	assert.Equal(t, "b", o.get()["message"])
+	assert.True(t, o.empty())
+}
+
+// newBlockedOutputChannel returns an OutputChannel with a buffer of 2 messages
+// whose consumer is blocked writing a first message until release is closed.
+func newBlockedOutputChannel(opts OutputChannelOptions) (oc *OutputChannel, o *testOutput, release chan struct{}) {
+	o = newTestOutput()
+	release = make(chan struct{})
+	opts.BufferSize = 2
+	oc = NewOutputChannelWithOptions(OutputFunc(func(fields map[string]interface{}) error {
+		<-release
+		return o.Write(fields)
+	}), opts)
+	oc.Write(F{"message": "first"})
+	for {
+		oc.mu.Lock()
+		n := oc.queue.len()
+		oc.mu.Unlock()
+		if n == 0 {
+			return
+		}
+		runtime.Gosched()
+	}
+}
+
+func TestOutputChannelDropNewest(t *testing.T) {
+	oc, o, release := newBlockedOutputChannel(OutputChannelOptions{})
+	defer oc.Close()
+	assert.NoError(t, oc.Write(F{"message": "a"}))
+	assert.NoError(t, oc.Write(F{"message": "b"}))
+	assert.Equal(t, ErrBufferFull, oc.Write(F{"message": "c"}))
+	close(release)
+	assert.Equal(t, "first", o.get()["message"])
+	assert.Equal(t, "messages dropped", o.get()["message"])
+	assert.Equal(t, "a", o.get()["message"])
+	assert.Equal(t, "b", o.get()["message"])
+}
+
+func TestOutputChannelDropOldest(t *testing.T) {
+	oc, o, release := newBlockedOutputChannel(OutputChannelOptions{DropPolicy: DropOldest})
+	defer oc.Close()
+	assert.NoError(t, oc.Write(F{"message": "a"}))
+	assert.NoError(t, oc.Write(F{"message": "b"}))
+	assert.NoError(t, oc.Write(F{"message": "c"}))
+	close(release)
+	assert.Equal(t, "first", o.get()["message"])
+	assert.Equal(t, "messages dropped", o.get()["message"])
+	assert.Equal(t, "b", o.get()["message"])
+	assert.Equal(t, "c", o.get()["message"])
+}
+
+func TestOutputChannelDropLowestLevel(t *testing.T) {
+	oc, o, release := newBlockedOutputChannel(OutputChannelOptions{DropPolicy: DropLowestLevel})
+	defer oc.Close()
+	assert.NoError(t, oc.Write(F{"level": "info", "message": "i"}))
+	assert.NoError(t, oc.Write(F{"level": "debug", "message": "d"}))
+	assert.NoError(t, oc.Write(F{"level": "warn", "message": "w1"}))
+	assert.NoError(t, oc.Write(F{"level": "error", "message": "e"}))
+	assert.Equal(t, ErrBufferFull, oc.Write(F{"level": "warn", "message": "w2"}))
+	close(release)
+	assert.Equal(t, "first", o.get()["message"])
+	assert.Equal(t, "messages dropped", o.get()["message"])
+	assert.Equal(t, "w1", o.get()["message"])
+	assert.Equal(t, "e", o.get()["message"])
+	assert.True(t, o.empty())
+}
+
+func TestOutputChannelBlock(t *testing.T) {
+	oc, o, release := newBlockedOutputChannel(OutputChannelOptions{DropPolicy: Block, BlockTimeout: 10 * time.Millisecond})
+	defer oc.Close()
+	assert.NoError(t, oc.Write(F{"message": "a"}))
+	assert.NoError(t, oc.Write(F{"message": "b"}))
+	start := time.Now()
+	assert.Equal(t, ErrBufferFull, oc.Write(F{"message": "c"}))
+	assert.True(t, time.Since(start) >= 10*time.Millisecond)
+	oc.opts.BlockTimeout = 0
+	done := make(chan error)
+	go func() {
+		done <- oc.Write(F{"message": "d"})
+	}()
+	close(release)
+	assert.NoError(t, <-done)
+	assert.Equal(t, "first", o.get()["message"])
+	assert.Equal(t, "messages dropped", o.get()["message"])
+	assert.Equal(t, "a", o.get()["message"])
+	assert.Equal(t, "b", o.get()["message"])
+	assert.Equal(t, "d", o.get()["message"])
+}
+
+func TestOutputChannelDropReport(t *testing.T) {
+	oc, o, release := newBlockedOutputChannel(OutputChannelOptions{ReportInterval: time.Hour})
+	defer oc.Close()
+	oc.Write(F{"level": "info", "message": "a"})
+	oc.Write(F{"level": "info", "message": "b"})
+	oc.Write(F{"level": "debug", "message": "c"})
+	oc.Write(F{"level": "debug", "message": "d"})
+	oc.Write(F{"level": "error", "message": "e"})
+	close(release)
+	assert.Equal(t, "first", o.get()["message"])
+	r := o.get()
+	assert.Equal(t, "messages dropped", r["message"])
+	assert.Equal(t, "warn", r["level"])
+	assert.Equal(t, 3, r["dropped"])
+	assert.Equal(t, fakeNow, r["since"])
+	assert.Equal(t, F{"debug": 2, "error": 1}, r["levels"])
+	assert.Equal(t, "a", o.get()["message"])
+	assert.Equal(t, "b", o.get()["message"])
+
+	// Next report is delayed by ReportInterval, even on Flush, but written on Close
+	oc.mu.Lock()
+	oc.drop(F{"level": "info"})
+	oc.mu.Unlock()
+	oc.Write(F{"message": "f"})
+	assert.Equal(t, "f", o.get()["message"])
+	assert.True(t, o.empty())
+	oc.Flush()
+	assert.True(t, o.empty())
+	oc.Close()
+	r = o.get()
+	assert.Equal(t, "messages dropped", r["message"])
+	assert.Equal(t, 1, r["dropped"])
+	assert.Equal(t, F{"info": 1}, r["levels"])
+}
+
+func TestOutputChannelBatch(t *testing.T) {
+	batches := make(chan []map[string]interface{}, 10)
+	o := BatchOutputFunc(func(msgs []map[string]interface{}) error {
+		batches <- msgs
+		return nil
+	})
+	oc := NewOutputChannelWithOptions(o, OutputChannelOptions{BatchSize: 3, BatchInterval: 20 * time.Millisecond})
+	defer oc.Close()
+	oc.Write(F{"message": "a"})
+	oc.Write(F{"message": "b"})
+	oc.Write(F{"message": "c"})
+	oc.Write(F{"message": "d"})
+	b := <-batches
+	if assert.Len(t, b, 3) {
+		assert.Equal(t, "a", b[0]["message"])
+		assert.Equal(t, "c", b[2]["message"])
+	}
+	// The partial batch is written once BatchInterval has elapsed
+	start := time.Now()
+	b = <-batches
+	assert.True(t, time.Since(start) >= 10*time.Millisecond)
+	if assert.Len(t, b, 1) {
+		assert.Equal(t, "d", b[0]["message"])
+	}
+
+	// Partial batches are written on Flush
+	oc.mu.Lock()
+	oc.opts.BatchInterval = time.Hour
+	oc.mu.Unlock()
+	oc.Write(F{"message": "e"})
+	oc.Write(F{"message": "f"})
+	oc.Flush()
+	b = <-batches
+	assert.Len(t, b, 2)
+	s := oc.Stats()
+	assert.Equal(t, uint64(6), s.Written)
+	assert.Equal(t, uint64(3), s.Writes)
+}
+
+func TestOutputChannelBatchLargerThanBuffer(t *testing.T) {
+	var n int
+	o := BatchOutputFunc(func(msgs []map[string]interface{}) error {
+		n += len(msgs)
+		return nil
+	})
+	oc := NewOutputChannelWithOptions(o, OutputChannelOptions{
+		BufferSize:    10,
+		BatchSize:     50,
+		BatchInterval: time.Hour,
+		DropPolicy:    Block,
+		BlockTimeout:  100 * time.Millisecond,
+	})
+	assert.Equal(t, 10, oc.opts.BatchSize)
+	start := time.Now()
+	for i := 0; i < 100; i++ {
+		assert.NoError(t, oc.Write(F{}))
+	}
+	assert.True(t, time.Since(start) < time.Second)
+	oc.Close()
+	assert.Equal(t, 100, n)
+	assert.Equal(t, uint64(0), oc.Stats().Dropped)
+}
+
+func TestOutputChannelBatchInterval(t *testing.T) {
+	release := make(chan struct{})
+	batches := make(chan []map[string]interface{}, 10)
+	times := make(chan time.Time, 10)
+	o := BatchOutputFunc(func(msgs []map[string]interface{}) error {
+		if msgs[0]["message"] == "first" {
+			<-release
+		}
+		batches <- msgs
+		times <- time.Now()
+		return nil
+	})
+	oc := NewOutputChannelWithOptions(o, OutputChannelOptions{BatchSize: 2, BatchInterval: 20 * time.Millisecond})
+	defer oc.Close()
+	oc.Write(F{"message": "first"})
+	// Wait for the consumer to be blocked writing first
+	time.Sleep(40 * time.Millisecond)
+	oc.Write(F{"message": "a"})
+	oc.Write(F{"message": "b"})
+	oc.Write(F{"message": "c"})
+	// c is queued for longer than BatchInterval but a new batch is started once
+	// the full batch with a and b is written
+	time.Sleep(40 * time.Millisecond)
+	close(release)
+	assert.Len(t, <-batches, 1)
+	<-times
+	assert.Len(t, <-batches, 2)
+	ab := <-times
+	assert.Len(t, <-batches, 1)
+	assert.True(t, (<-times).Sub(ab) >= 10*time.Millisecond)
+}
+
+func TestOutputChannelBatchOutput(t *testing.T) {
+	o := newTestOutput()
+	oc := NewOutputChannelWithOptions(o, OutputChannelOptions{BatchSize: 2, BatchInterval: time.Hour})
+	oc.Write(F{"message": "a"})
+	oc.Write(F{"message": "b"})
+	assert.Equal(t, "a", o.get()["message"])
+	assert.Equal(t, "b", o.get()["message"])
+	oc.Write(F{"message": "c"})
+	oc.Close()
+	assert.Equal(t, "c", o.get()["message"])
+}
+
+func TestNewBatchOutput(t *testing.T) {
+	o := newTestOutput()
+	b := NewBatchOutput(o)
+	assert.NoError(t, b.WriteBatch([]map[string]interface{}{{"message": "a"}, {"message": "b"}}))
+	assert.Equal(t, "a", o.get()["message"])
+	assert.Equal(t, "b", o.get()["message"])
+	err := errors.New("some error")
+	b = NewBatchOutput(MultiOutput{newTestOutputErr(err)})
+	assert.Equal(t, err, b.WriteBatch([]map[string]interface{}{{}, {}}))
+	bo := BatchOutputFunc(func(msgs []map[string]interface{}) error {
+		return nil
+	})
+	_, ok := NewBatchOutput(bo).(BatchOutputFunc)
+	assert.True(t, ok)
 }
 
 func TestDiscard(t *testing.T) {
@@ -195,6 +511,30 @@ func TestLevelOutput(t *testing.T) {
 	assert.True(t, oFatal.empty())
 	assert.True(t, oWarn.empty())
 
+	reset()
+	oTrace := newTestOutput()
+	oNotice := newTestOutput()
+	oCritical := newTestOutput()
+	oPanic := newTestOutput()
+	l = LevelOutput{
+		Trace:    oTrace,
+		Notice:   oNotice,
+		Critical: oCritical,
+		Panic:    oPanic,
+	}
+	assert.NoError(t, l.Write(F{"level": "trace", "foo": "bar"}))
+	assert.Equal(t, F{"level": "trace", "foo": "bar"}, F(<-oTrace.w))
+	assert.NoError(t, l.Write(F{"level": "notice", "foo": "bar"}))
+	assert.Equal(t, F{"level": "notice", "foo": "bar"}, F(<-oNotice.w))
+	assert.NoError(t, l.Write(F{"level": "critical", "foo": "bar"}))
+	assert.Equal(t, F{"level": "critical", "foo": "bar"}, F(<-oCritical.w))
+	assert.NoError(t, l.Write(F{"level": "panic", "foo": "bar"}))
+	assert.Equal(t, F{"level": "panic", "foo": "bar"}, F(<-oPanic.w))
+	assert.True(t, oTrace.empty())
+	assert.True(t, oNotice.empty())
+	assert.True(t, oCritical.empty())
+	assert.True(t, oPanic.empty())
+
 	reset()
 	err = l.Write(F{"foo": "bar"})
 	assert.NoError(t, err)
@@ -276,6 +616,22 @@ func TestConsoleOutput(t *testing.T) {
 	err = c.Write(F{"message": "some error", "level": "error"})
 	assert.NoError(t, err)
 	assert.Equal(t, "\x1b[31mERRO\x1b[0m some error\n", buf.String())
+	buf.Reset()
+	err = c.Write(F{"message": "some trace", "level": "trace"})
+	assert.NoError(t, err)
+	assert.Equal(t, "\x1b[37mTRAC\x1b[0m some trace\n", buf.String())
+	buf.Reset()
+	err = c.Write(F{"message": "some notice", "level": "notice"})
+	assert.NoError(t, err)
+	assert.Equal(t, "\x1b[36mNOTI\x1b[0m some notice\n", buf.String())
+	buf.Reset()
+	err = c.Write(F{"message": "some critical", "level": "critical"})
+	assert.NoError(t, err)
+	assert.Equal(t, "\x1b[31mCRIT\x1b[0m some critical\n", buf.String())
+	buf.Reset()
+	err = c.Write(F{"message": "some fatal", "level": "fatal"})
+	assert.NoError(t, err)
+	assert.Equal(t, "\x1b[31mFATA\x1b[0m some fatal\n", buf.String())
 }
 
 func TestLogfmtOutput(t *testing.T) {
@@ -292,7 +648,7 @@ func TestLogfmtOutput(t *testing.T) {
 		"errq":    errors.New("error with \" quote"),
 	})
 	assert.NoError(t, err)
-	assert.Equal(t, "level=info message=\"some message\" time=\"2000-01-02 03:04:05 +0000 UTC\" err=error errq=\"error with \\\" quote\" null=null quoted=\"needs \\\" quotes\" string=foo\n", buf.String())
+	assert.Equal(t, "level=info message=\"some message\" time=\"2000-01-02 03:04:05 +0000 UTC\" err=error err.type=*errors.errorString errq=\"error with \\\" quote\" errq.type=*errors.errorString null=null quoted=\"needs \\\" quotes\" string=foo\n", buf.String())
 }
 
 func TestJSONOutput(t *testing.T) {
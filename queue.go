@@ -0,0 +1,61 @@
+package xlog
+
+// messageQueue is a fixed capacity FIFO queue of messages backed by a ring
+// buffer, so messages are queued and dequeued in constant time.
+type messageQueue struct {
+	msgs []map[string]interface{}
+	head int
+	n    int
+}
+
+func newMessageQueue(size int) messageQueue {
+	return messageQueue{msgs: make([]map[string]interface{}, size)}
+}
+
+// len returns the number of queued messages.
+func (q *messageQueue) len() int {
+	return q.n
+}
+
+// at returns the i-th oldest message.
+func (q *messageQueue) at(i int) map[string]interface{} {
+	return q.msgs[(q.head+i)%len(q.msgs)]
+}
+
+// push queues msg. The queue must not be full.
+func (q *messageQueue) push(msg map[string]interface{}) {
+	q.msgs[(q.head+q.n)%len(q.msgs)] = msg
+	q.n++
+}
+
+// remove removes the i-th oldest message, moving the messages on the shortest
+// side of the queue to fill the gap.
+func (q *messageQueue) remove(i int) {
+	c := len(q.msgs)
+	if i < q.n/2 {
+		for j := i; j > 0; j-- {
+			q.msgs[(q.head+j)%c] = q.msgs[(q.head+j-1)%c]
+		}
+		q.msgs[q.head] = nil
+		q.head = (q.head + 1) % c
+	} else {
+		for j := i; j < q.n-1; j++ {
+			q.msgs[(q.head+j)%c] = q.msgs[(q.head+j+1)%c]
+		}
+		q.msgs[(q.head+q.n-1)%c] = nil
+	}
+	q.n--
+}
+
+// pop dequeues the n oldest messages. The queue must hold at least n messages.
+func (q *messageQueue) pop(n int) []map[string]interface{} {
+	msgs := make([]map[string]interface{}, n)
+	c := len(q.msgs)
+	for i := range msgs {
+		msgs[i] = q.msgs[q.head]
+		q.msgs[q.head] = nil
+		q.head = (q.head + 1) % c
+	}
+	q.n -= n
+	return msgs
+}
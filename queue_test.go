@@ -0,0 +1,44 @@
+package xlog
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func queueMessages(q *messageQueue) []interface{} {
+	var msgs []interface{}
+	for i := 0; i < q.len(); i++ {
+		msgs = append(msgs, q.at(i)["message"])
+	}
+	return msgs
+}
+
+func TestMessageQueue(t *testing.T) {
+	q := newMessageQueue(4)
+	for _, m := range []string{"a", "b", "c"} {
+		q.push(F{"message": m})
+	}
+	msgs := q.pop(2)
+	assert.Equal(t, []map[string]interface{}{{"message": "a"}, {"message": "b"}}, msgs)
+	// Wrap around the end of the ring
+	for _, m := range []string{"d", "e", "f"} {
+		q.push(F{"message": m})
+	}
+	assert.Equal(t, []interface{}{"c", "d", "e", "f"}, queueMessages(&q))
+	q.remove(1)
+	assert.Equal(t, []interface{}{"c", "e", "f"}, queueMessages(&q))
+	q.remove(2)
+	assert.Equal(t, []interface{}{"c", "e"}, queueMessages(&q))
+	q.remove(0)
+	assert.Equal(t, []interface{}{"e"}, queueMessages(&q))
+	q.push(F{"message": "g"})
+	q.push(F{"message": "h"})
+	q.push(F{"message": "i"})
+	assert.Equal(t, []interface{}{"e", "g", "h", "i"}, queueMessages(&q))
+	assert.Len(t, q.pop(4), 4)
+	assert.Equal(t, 0, q.len())
+	for _, msg := range q.msgs {
+		assert.Nil(t, msg)
+	}
+}
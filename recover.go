@@ -0,0 +1,27 @@
+package xlog
+
+import (
+	"fmt"
+	"strings"
+)
+
+// logPanic logs the value v recovered from a panic along with the stack trace
+// of the panicking go routine. It must be called by the function deferred to
+// recover from the panic.
+func logPanic(l Logger, v interface{}) {
+	// Skip logPanic, the deferred function and the frames of the runtime raising
+	// the panic so the stack starts with the panicking function
+	stack := stackTrace(2)
+	skip := 0
+	for skip < len(stack)-1 && strings.HasPrefix(stack[skip].Func, "runtime.") {
+		skip++
+	}
+	f := F{KeyStack: stack[skip:]}
+	msg := fmt.Sprint("panic: ", v)
+	if l, ok := l.(*logger); ok {
+		// Report the panicking function as the caller
+		l.send(LevelPanic, 3+skip, msg, f)
+		return
+	}
+	l.Error(msg, f)
+}
@@ -0,0 +1,175 @@
+package xlog
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/rs/xid"
+)
+
+// IDGenerator generates the unique ids of the requests and validates the ids
+// received from upstream services.
+type IDGenerator interface {
+	// NewID returns a new unique id.
+	NewID() string
+	// Valid returns true if id is a well formed id of the generator's format.
+	Valid(id string) bool
+}
+
+// Built-in request id generators.
+var (
+	// XIDGenerator generates 20 characters base32 encoded mongo object-id-like
+	// unique ids using github.com/rs/xid.
+	XIDGenerator IDGenerator = xidGenerator{}
+	// UUIDv4Generator generates random RFC 4122 UUIDs. Any UUID is valid.
+	UUIDv4Generator IDGenerator = uuidGenerator{version: 4}
+	// UUIDv7Generator generates time ordered RFC 9562 version 7 UUIDs. Any UUID
+	// is valid.
+	UUIDv7Generator IDGenerator = uuidGenerator{version: 7}
+	// ULIDGenerator generates 26 characters time ordered ULIDs.
+	ULIDGenerator IDGenerator = ulidGenerator{}
+)
+
+type xidGenerator struct{}
+
+func (xidGenerator) NewID() string {
+	return xid.New().String()
+}
+
+func (xidGenerator) Valid(id string) bool {
+	_, err := xid.FromString(id)
+	return err == nil
+}
+
+type uuidGenerator struct {
+	version byte
+}
+
+func (g uuidGenerator) NewID() string {
+	var u [16]byte
+	randomID(u[:])
+	if g.version == 7 {
+		var ts [8]byte
+		binary.BigEndian.PutUint64(ts[:], uint64(now().UnixNano()/1e6))
+		copy(u[:6], ts[2:])
+	}
+	u[6] = u[6]&0x0f | g.version<<4
+	u[8] = u[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[:4], u[4:6], u[6:8], u[8:10], u[10:])
+}
+
+func (uuidGenerator) Valid(id string) bool {
+	if len(id) != 36 {
+		return false
+	}
+	for i, c := range id {
+		switch i {
+		case 8, 13, 18, 23:
+			if c != '-' {
+				return false
+			}
+		default:
+			if !isHex(c) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func isHex(c rune) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
+
+// crockford is the Crockford's base32 alphabet used by ULIDs.
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+type ulidGenerator struct{}
+
+func (ulidGenerator) NewID() string {
+	// 48 bits of timestamp in ms and 80 bits of randomness, encoded 5 bits at a
+	// time from the most significant ones after 2 bits of padding
+	var u [16]byte
+	var ts [8]byte
+	binary.BigEndian.PutUint64(ts[:], uint64(now().UnixNano()/1e6))
+	copy(u[:6], ts[2:])
+	randomID(u[6:])
+	hi := binary.BigEndian.Uint64(u[:8])
+	lo := binary.BigEndian.Uint64(u[8:])
+	b := make([]byte, 26)
+	for i := 25; i >= 0; i-- {
+		b[i] = crockford[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(b)
+}
+
+func (ulidGenerator) Valid(id string) bool {
+	if len(id) != 26 || id[0] > '7' {
+		return false
+	}
+	for _, c := range strings.ToUpper(id) {
+		if !strings.ContainsRune(crockford, c) {
+			return false
+		}
+	}
+	return true
+}
+
+// RequestIDOptions configures the handler returned by RequestIDHandlerWithOptions.
+type RequestIDOptions struct {
+	// Name is the name of the logger field set with the id. No field is set if
+	// empty.
+	Name string
+	// Header is the name of the response header set with the id. No header is
+	// set if empty.
+	Header string
+	// TrustHeader makes the handler use the id received in the Header request
+	// header if it is valid, so ids are kept across services.
+	TrustHeader bool
+	// Generator generates the ids of the requests without a valid inbound id.
+	// Defaults to XIDGenerator.
+	Generator IDGenerator
+	// Validate returns true if an inbound id is acceptable. Defaults to the
+	// Generator's Valid method. Inbound ids are always limited to 128 printable
+	// ASCII characters.
+	Validate func(id string) bool
+}
+
+// maxRequestIDLength is the maximum length of an inbound request id.
+const maxRequestIDLength = 128
+
+// requestID returns the id of a request with header h, generating a new one if
+// the inbound id is not trusted or not valid.
+func (o RequestIDOptions) requestID(h http.Header) string {
+	g := o.Generator
+	if g == nil {
+		g = XIDGenerator
+	}
+	if in := h.Get(o.Header); o.TrustHeader && in != "" && validRequestID(in) {
+		valid := o.Validate
+		if valid == nil {
+			valid = g.Valid
+		}
+		if valid(in) {
+			return in
+		}
+	}
+	return g.NewID()
+}
+
+// validRequestID checks id can safely be logged and sent back in a header.
+func validRequestID(id string) bool {
+	if len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,61 @@
+package xlog
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIDGenerators(t *testing.T) {
+	for _, g := range []IDGenerator{XIDGenerator, UUIDv4Generator, UUIDv7Generator, ULIDGenerator} {
+		id := g.NewID()
+		assert.True(t, g.Valid(id), id)
+		assert.NotEqual(t, id, g.NewID())
+		assert.False(t, g.Valid(""))
+		assert.False(t, g.Valid(id[1:]), id)
+		assert.False(t, g.Valid(id[1:]+"!"), id)
+	}
+}
+
+func TestUUIDGenerator(t *testing.T) {
+	id := UUIDv4Generator.NewID()
+	assert.Equal(t, byte('4'), id[14])
+	assert.Contains(t, "89ab", string(id[19]))
+
+	defer func(n func() time.Time) { now = n }(now)
+	now = func() time.Time { return time.Unix(1645557742, 0) }
+	// 2022-02-22T19:22:22Z is 0x017f22e279b0 in ms
+	id = UUIDv7Generator.NewID()
+	assert.True(t, strings.HasPrefix(id, "017f22e2-79b0-7"), id)
+	assert.Contains(t, "89ab", string(id[19]))
+}
+
+func TestULIDGenerator(t *testing.T) {
+	defer func(n func() time.Time) { now = n }(now)
+	now = func() time.Time { return time.Unix(1469918176, 385000000) }
+	id := ULIDGenerator.NewID()
+	assert.Len(t, id, 26)
+	assert.True(t, strings.HasPrefix(id, "01ARYZ6S41"), id)
+	assert.False(t, ULIDGenerator.Valid("81ARYZ6S41TSV4RRFFQ69G5FAV"))
+	assert.True(t, ULIDGenerator.Valid("01aryz6s41tsv4rrffq69g5fav"))
+}
+
+func TestRequestIDOptions(t *testing.T) {
+	h := http.Header{"Request-Id": {"abc"}}
+	o := RequestIDOptions{Header: "Request-Id"}
+	// Not trusted
+	assert.Len(t, o.requestID(h), 20)
+	o.TrustHeader = true
+	// Not an xid
+	assert.Len(t, o.requestID(h), 20)
+	o.Validate = func(id string) bool { return len(id) <= 3 }
+	assert.Equal(t, "abc", o.requestID(h))
+	assert.Len(t, o.requestID(http.Header{"Request-Id": {"abcd"}}), 20)
+	// Unsafe ids are always rejected
+	assert.Len(t, o.requestID(http.Header{"Request-Id": {"a\n"}}), 20)
+	o.Validate = func(id string) bool { return true }
+	assert.Len(t, o.requestID(http.Header{"Request-Id": {strings.Repeat("a", 129)}}), 20)
+}
@@ -0,0 +1,215 @@
+package xlog
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+// responseWriter wraps an http.ResponseWriter to record the status code and the
+// size of the response.
+//
+// The http.ResponseWriter returned by wrap only implements the optional
+// http.Flusher, http.Hijacker, io.ReaderFrom and, with Go 1.8+, http.Pusher
+// interfaces if the wrapped writer does, so the next handlers can still detect
+// the features of the connection. The wrapped writer is also exposed thru Unwrap
+// for http.ResponseController.
+type responseWriter struct {
+	w      http.ResponseWriter
+	status int
+	size   int64
+}
+
+func newResponseWriter(w http.ResponseWriter) *responseWriter {
+	return &responseWriter{w: w}
+}
+
+// Status returns the status code sent or http.StatusOK if none has been sent
+// explicitly.
+func (rw *responseWriter) Status() int {
+	if rw.status == 0 {
+		return http.StatusOK
+	}
+	return rw.status
+}
+
+// Header implements http.ResponseWriter interface
+func (rw *responseWriter) Header() http.Header {
+	return rw.w.Header()
+}
+
+// WriteHeader implements http.ResponseWriter interface
+func (rw *responseWriter) WriteHeader(status int) {
+	// Informational responses other than 101 are followed by the final one
+	if rw.status == 0 && (status >= 200 || status == http.StatusSwitchingProtocols) {
+		rw.status = status
+	}
+	rw.w.WriteHeader(status)
+}
+
+// Write implements http.ResponseWriter interface
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	rw.setDefaultStatus()
+	n, err := rw.w.Write(b)
+	rw.size += int64(n)
+	return n, err
+}
+
+// FlushError flushes the response, returning http.ErrNotSupported if the wrapped
+// writer does not support flushing. It is used by http.ResponseController.
+func (rw *responseWriter) FlushError() error {
+	switch f := rw.w.(type) {
+	case interface {
+		FlushError() error
+	}:
+		rw.setDefaultStatus()
+		return f.FlushError()
+	case http.Flusher:
+		rw.setDefaultStatus()
+		f.Flush()
+		return nil
+	}
+	return http.ErrNotSupported
+}
+
+// setDefaultStatus records the implicit http.StatusOK sent by the wrapped writer
+// when the response is written without calling WriteHeader first.
+func (rw *responseWriter) setDefaultStatus() {
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+}
+
+// Unwrap returns the wrapped http.ResponseWriter. It is used by
+// http.ResponseController.
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.w
+}
+
+// rwFlusher implements http.Flusher for a responseWriter.
+type rwFlusher struct {
+	rw *responseWriter
+}
+
+// Flush implements http.Flusher interface
+func (f rwFlusher) Flush() {
+	f.rw.FlushError()
+}
+
+// rwHijacker implements http.Hijacker for a responseWriter.
+type rwHijacker struct {
+	rw *responseWriter
+}
+
+// Hijack implements http.Hijacker interface
+func (h rwHijacker) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	c, buf, err := h.rw.w.(http.Hijacker).Hijack()
+	if err == nil && h.rw.status == 0 {
+		h.rw.status = http.StatusSwitchingProtocols
+	}
+	return c, buf, err
+}
+
+// rwReaderFrom implements io.ReaderFrom for a responseWriter.
+type rwReaderFrom struct {
+	rw *responseWriter
+}
+
+// ReadFrom implements io.ReaderFrom interface
+func (r rwReaderFrom) ReadFrom(src io.Reader) (int64, error) {
+	r.rw.setDefaultStatus()
+	n, err := r.rw.w.(io.ReaderFrom).ReadFrom(src)
+	r.rw.size += n
+	return n, err
+}
+
+// Optional interfaces of the wrapped writer
+const (
+	hasFlusher = 1 << iota
+	hasHijacker
+	hasReaderFrom
+)
+
+// features returns the optional interfaces implemented by the wrapped writer.
+func (rw *responseWriter) features() int {
+	features := 0
+	if _, ok := rw.w.(http.Flusher); ok {
+		features |= hasFlusher
+	}
+	if _, ok := rw.w.(http.Hijacker); ok {
+		features |= hasHijacker
+	}
+	if _, ok := rw.w.(io.ReaderFrom); ok {
+		features |= hasReaderFrom
+	}
+	return features
+}
+
+// wrapBasic returns rw implementing the same http.Flusher, http.Hijacker and
+// io.ReaderFrom interfaces as the wrapped writer.
+func (rw *responseWriter) wrapBasic() http.ResponseWriter {
+	f, h, r := rwFlusher{rw}, rwHijacker{rw}, rwReaderFrom{rw}
+	switch rw.features() {
+	case hasFlusher:
+		return struct {
+			*responseWriter
+			rwFlusher
+		}{rw, f}
+	case hasHijacker:
+		return struct {
+			*responseWriter
+			rwHijacker
+		}{rw, h}
+	case hasReaderFrom:
+		return struct {
+			*responseWriter
+			rwReaderFrom
+		}{rw, r}
+	case hasFlusher | hasHijacker:
+		return struct {
+			*responseWriter
+			rwFlusher
+			rwHijacker
+		}{rw, f, h}
+	case hasFlusher | hasReaderFrom:
+		return struct {
+			*responseWriter
+			rwFlusher
+			rwReaderFrom
+		}{rw, f, r}
+	case hasHijacker | hasReaderFrom:
+		return struct {
+			*responseWriter
+			rwHijacker
+			rwReaderFrom
+		}{rw, h, r}
+	case hasFlusher | hasHijacker | hasReaderFrom:
+		return struct {
+			*responseWriter
+			rwFlusher
+			rwHijacker
+			rwReaderFrom
+		}{rw, f, h, r}
+	}
+	return rw
+}
+
+// logAccess logs the access log message of request r answered thru rw in d.
+func logAccess(l Logger, r *http.Request, rw *responseWriter, d time.Duration) {
+	status := rw.Status()
+	f := F{
+		KeyStatus:   status,
+		KeySize:     rw.size,
+		KeyDuration: d,
+	}
+	switch {
+	case status >= 500:
+		l.Errorf("%s %s %03d", r.Method, r.URL, status, f)
+	case status >= 400:
+		l.Warnf("%s %s %03d", r.Method, r.URL, status, f)
+	default:
+		l.Infof("%s %s %03d", r.Method, r.URL, status, f)
+	}
+}
@@ -0,0 +1,36 @@
+// +build go1.20
+
+package xlog
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type deadlineWriter struct {
+	basicWriter
+	deadline time.Time
+}
+
+func (w *deadlineWriter) SetWriteDeadline(t time.Time) error {
+	w.deadline = t
+	return nil
+}
+
+func TestResponseWriterController(t *testing.T) {
+	rec := httptest.NewRecorder()
+	rc := http.NewResponseController(newResponseWriter(rec))
+	assert.NoError(t, rc.Flush())
+	assert.True(t, rec.Flushed)
+	assert.ErrorIs(t, rc.SetWriteDeadline(time.Time{}), http.ErrNotSupported)
+
+	w := &deadlineWriter{}
+	rc = http.NewResponseController(newResponseWriter(w))
+	assert.ErrorIs(t, rc.Flush(), http.ErrNotSupported)
+	assert.NoError(t, rc.SetWriteDeadline(fakeNow))
+	assert.Equal(t, fakeNow, w.deadline)
+}
@@ -0,0 +1,77 @@
+// +build go1.8
+
+package xlog
+
+import "net/http"
+
+// rwPusher implements http.Pusher for a responseWriter.
+type rwPusher struct {
+	rw *responseWriter
+}
+
+// Push implements http.Pusher interface
+func (p rwPusher) Push(target string, opts *http.PushOptions) error {
+	return p.rw.w.(http.Pusher).Push(target, opts)
+}
+
+// wrap returns rw implementing the same optional interfaces as the wrapped
+// writer among http.Flusher, http.Hijacker, io.ReaderFrom and http.Pusher.
+func (rw *responseWriter) wrap() http.ResponseWriter {
+	if _, ok := rw.w.(http.Pusher); !ok {
+		return rw.wrapBasic()
+	}
+	f, h, r, p := rwFlusher{rw}, rwHijacker{rw}, rwReaderFrom{rw}, rwPusher{rw}
+	switch rw.features() {
+	case hasFlusher:
+		return struct {
+			*responseWriter
+			rwFlusher
+			rwPusher
+		}{rw, f, p}
+	case hasHijacker:
+		return struct {
+			*responseWriter
+			rwHijacker
+			rwPusher
+		}{rw, h, p}
+	case hasReaderFrom:
+		return struct {
+			*responseWriter
+			rwReaderFrom
+			rwPusher
+		}{rw, r, p}
+	case hasFlusher | hasHijacker:
+		return struct {
+			*responseWriter
+			rwFlusher
+			rwHijacker
+			rwPusher
+		}{rw, f, h, p}
+	case hasFlusher | hasReaderFrom:
+		return struct {
+			*responseWriter
+			rwFlusher
+			rwReaderFrom
+			rwPusher
+		}{rw, f, r, p}
+	case hasHijacker | hasReaderFrom:
+		return struct {
+			*responseWriter
+			rwHijacker
+			rwReaderFrom
+			rwPusher
+		}{rw, h, r, p}
+	case hasFlusher | hasHijacker | hasReaderFrom:
+		return struct {
+			*responseWriter
+			rwFlusher
+			rwHijacker
+			rwReaderFrom
+			rwPusher
+		}{rw, f, h, r, p}
+	}
+	return struct {
+		*responseWriter
+		rwPusher
+	}{rw, p}
+}
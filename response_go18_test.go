@@ -0,0 +1,45 @@
+// +build go1.8
+
+package xlog
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type pushWriter struct {
+	basicWriter
+	pushed string
+}
+
+func (w *pushWriter) Push(target string, opts *http.PushOptions) error {
+	w.pushed = target
+	return nil
+}
+
+func TestResponseWriterPush(t *testing.T) {
+	w := &pushWriter{}
+	p, ok := newResponseWriter(w).wrap().(http.Pusher)
+	if assert.True(t, ok) {
+		assert.NoError(t, p.Push("/style.css", nil))
+		assert.Equal(t, "/style.css", w.pushed)
+	}
+
+	_, ok = newResponseWriter(&basicWriter{}).wrap().(http.Pusher)
+	assert.False(t, ok)
+
+	// HTTP/2 writers flush and push but can't be hijacked
+	rw := newResponseWriter(struct {
+		*pushWriter
+		http.Flusher
+	}{&pushWriter{}, httptest.NewRecorder()}).wrap()
+	_, ok = rw.(http.Pusher)
+	assert.True(t, ok)
+	_, ok = rw.(http.Flusher)
+	assert.True(t, ok)
+	_, ok = rw.(http.Hijacker)
+	assert.False(t, ok)
+}
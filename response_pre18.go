@@ -0,0 +1,11 @@
+// +build !go1.8
+
+package xlog
+
+import "net/http"
+
+// wrap returns rw implementing the same optional interfaces as the wrapped
+// writer among http.Flusher, http.Hijacker and io.ReaderFrom.
+func (rw *responseWriter) wrap() http.ResponseWriter {
+	return rw.wrapBasic()
+}
@@ -0,0 +1,145 @@
+package xlog
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// basicWriter is a http.ResponseWriter without any optional interface.
+type basicWriter struct {
+	header http.Header
+	status int
+	buf    bytes.Buffer
+}
+
+func (w *basicWriter) Header() http.Header {
+	if w.header == nil {
+		w.header = http.Header{}
+	}
+	return w.header
+}
+
+func (w *basicWriter) WriteHeader(status int) {
+	w.status = status
+}
+
+func (w *basicWriter) Write(b []byte) (int, error) {
+	return w.buf.Write(b)
+}
+
+type hijackWriter struct {
+	basicWriter
+	hijacked bool
+	err      error
+}
+
+func (w *hijackWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.hijacked = true
+	return nil, nil, w.err
+}
+
+type readFromWriter struct {
+	basicWriter
+}
+
+func (w *readFromWriter) ReadFrom(src io.Reader) (int64, error) {
+	return w.buf.ReadFrom(src)
+}
+
+func TestResponseWriterStatus(t *testing.T) {
+	rw := newResponseWriter(&basicWriter{})
+	assert.Equal(t, http.StatusOK, rw.Status())
+	rw.WriteHeader(http.StatusContinue)
+	assert.Equal(t, http.StatusOK, rw.Status())
+	rw.WriteHeader(http.StatusNotFound)
+	rw.WriteHeader(http.StatusInternalServerError)
+	assert.Equal(t, http.StatusNotFound, rw.Status())
+
+	rw = newResponseWriter(&basicWriter{})
+	rw.Write([]byte("foo"))
+	rw.WriteHeader(http.StatusNotFound)
+	assert.Equal(t, http.StatusOK, rw.Status())
+}
+
+func TestResponseWriterInterfaces(t *testing.T) {
+	for _, tc := range []struct {
+		w                             http.ResponseWriter
+		flusher, hijacker, readerFrom bool
+	}{
+		{&basicWriter{}, false, false, false},
+		{httptest.NewRecorder(), true, false, false},
+		{&hijackWriter{}, false, true, false},
+		{&readFromWriter{}, false, false, true},
+		{struct {
+			*hijackWriter
+			io.ReaderFrom
+			http.Flusher
+		}{&hijackWriter{}, &readFromWriter{}, httptest.NewRecorder()}, true, true, true},
+	} {
+		w := newResponseWriter(tc.w).wrap()
+		_, ok := w.(http.Flusher)
+		assert.Equal(t, tc.flusher, ok, "%T flusher", tc.w)
+		_, ok = w.(http.Hijacker)
+		assert.Equal(t, tc.hijacker, ok, "%T hijacker", tc.w)
+		_, ok = w.(io.ReaderFrom)
+		assert.Equal(t, tc.readerFrom, ok, "%T readerFrom", tc.w)
+	}
+}
+
+func TestResponseWriterSize(t *testing.T) {
+	w := &readFromWriter{}
+	rw := newResponseWriter(w)
+	rw.Write([]byte("foo"))
+	n, err := rw.wrap().(io.ReaderFrom).ReadFrom(strings.NewReader("bar"))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), n)
+	assert.Equal(t, int64(6), rw.size)
+	assert.Equal(t, "foobar", w.buf.String())
+
+	rw = newResponseWriter(&readFromWriter{})
+	rw.wrap().(io.ReaderFrom).ReadFrom(strings.NewReader("foo"))
+	assert.Equal(t, int64(3), rw.size)
+	assert.Equal(t, http.StatusOK, rw.status)
+}
+
+func TestResponseWriterFlush(t *testing.T) {
+	rec := httptest.NewRecorder()
+	rw := newResponseWriter(rec)
+	rw.wrap().(http.Flusher).Flush()
+	assert.True(t, rec.Flushed)
+	assert.Equal(t, http.StatusOK, rw.status)
+	assert.NoError(t, rw.FlushError())
+
+	rw = newResponseWriter(&basicWriter{})
+	assert.Equal(t, http.ErrNotSupported, rw.FlushError())
+	assert.Equal(t, 0, rw.status)
+}
+
+func TestResponseWriterHijack(t *testing.T) {
+	w := &hijackWriter{}
+	rw := newResponseWriter(w)
+	_, _, err := rw.wrap().(http.Hijacker).Hijack()
+	assert.NoError(t, err)
+	assert.True(t, w.hijacked)
+	assert.Equal(t, http.StatusSwitchingProtocols, rw.Status())
+
+	w = &hijackWriter{err: errors.New("hijack error")}
+	rw = newResponseWriter(w)
+	_, _, err = rw.wrap().(http.Hijacker).Hijack()
+	assert.EqualError(t, err, "hijack error")
+	assert.Equal(t, 0, rw.status)
+}
+
+func TestResponseWriterUnwrap(t *testing.T) {
+	w := &basicWriter{}
+	assert.Equal(t, w, newResponseWriter(w).Unwrap())
+}
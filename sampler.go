@@ -0,0 +1,228 @@
+package xlog
+
+import (
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Sampler decides which messages are output by a logger. Samplers are shared by
+// all loggers created from the same Config and must be safe for concurrent use.
+type Sampler interface {
+	// Sample returns the sampling rate of a message with the given level and
+	// template, i.e. the number of messages the message stands for if it is
+	// output, or 0 if the message must be discarded. The template is the format
+	// of messages sent with the formatting methods (Infof, …) or the message
+	// itself.
+	Sample(level Level, template string) (rate int)
+}
+
+// SamplerFunc is an adapter to allow the use of ordinary functions as Sampler.
+type SamplerFunc func(level Level, template string) int
+
+// Sample implements the Sampler interface
+func (sf SamplerFunc) Sample(level Level, template string) int {
+	return sf(level, template)
+}
+
+// LevelSampler routes messages to different samplers based on the message's
+// level. Messages with no sampler set for their level are not sampled.
+type LevelSampler struct {
+	Trace    Sampler
+	Debug    Sampler
+	Info     Sampler
+	Notice   Sampler
+	Warn     Sampler
+	Error    Sampler
+	Critical Sampler
+	Fatal    Sampler
+	Panic    Sampler
+}
+
+// Sample implements the Sampler interface
+func (s LevelSampler) Sample(level Level, template string) int {
+	var ss Sampler
+	switch level {
+	case LevelTrace:
+		ss = s.Trace
+	case LevelDebug:
+		ss = s.Debug
+	case LevelInfo:
+		ss = s.Info
+	case LevelNotice:
+		ss = s.Notice
+	case LevelWarn:
+		ss = s.Warn
+	case LevelError:
+		ss = s.Error
+	case LevelCritical:
+		ss = s.Critical
+	case LevelFatal:
+		ss = s.Fatal
+	case LevelPanic:
+		ss = s.Panic
+	}
+	if ss != nil {
+		return ss.Sample(level, template)
+	}
+	return 1
+}
+
+// burstCounter counts messages over a period of time.
+type burstCounter struct {
+	start time.Time
+	n     int
+}
+
+// sample returns the sampling rate of a new message counted at t.
+func (c *burstCounter) sample(t time.Time, period time.Duration, first, thereafter int) int {
+	if period <= 0 {
+		period = time.Second
+	}
+	if t.Sub(c.start) >= period || t.Before(c.start) {
+		c.start = t
+		c.n = 0
+	}
+	c.n++
+	if c.n <= first {
+		return 1
+	}
+	if thereafter > 0 && (c.n-first)%thereafter == 0 {
+		return thereafter
+	}
+	return 0
+}
+
+// BurstSampler outputs the First messages of every Period then one message out
+// of Thereafter for the remaining of the period.
+type BurstSampler struct {
+	// First is the number of messages output unsampled every period.
+	First int
+	// Thereafter defines the sampling rate applied once First messages have
+	// been output during the period. If 0, those messages are all discarded.
+	Thereafter int
+	// Period is the duration after which counters are reset. Defaults to one
+	// second.
+	Period time.Duration
+
+	mu sync.Mutex
+	c  burstCounter
+}
+
+// Sample implements the Sampler interface
+func (s *BurstSampler) Sample(level Level, template string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.c.sample(now(), s.Period, s.First, s.Thereafter)
+}
+
+// templateSamplerBuckets is the number of counters used by TemplateSampler.
+const templateSamplerBuckets = 4096
+
+// TemplateSampler is like BurstSampler but counts messages per level and message
+// template, so a flood of identical messages does not hide the other ones.
+//
+// Counters are hashed in a fixed number of buckets to bound memory usage, distinct
+// templates may thus share a counter on collision.
+type TemplateSampler struct {
+	// First is the number of messages of each template output unsampled every
+	// period.
+	First int
+	// Thereafter defines the sampling rate applied once First messages of a
+	// template have been output during the period. If 0, those messages are all
+	// discarded.
+	Thereafter int
+	// Period is the duration after which counters are reset. Defaults to one
+	// second.
+	Period time.Duration
+
+	mu       sync.Mutex
+	counters []burstCounter
+}
+
+// Sample implements the Sampler interface
+func (s *TemplateSampler) Sample(level Level, template string) int {
+	h := fnv.New32a()
+	h.Write([]byte{byte(level)})
+	h.Write([]byte(template))
+	i := h.Sum32() % templateSamplerBuckets
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.counters == nil {
+		s.counters = make([]burstCounter, templateSamplerBuckets)
+	}
+	return s.counters[i].sample(now(), s.Period, s.First, s.Thereafter)
+}
+
+// RandomSampler outputs a random ratio of the messages. A Ratio of 0.1 outputs
+// around one message out of ten.
+type RandomSampler struct {
+	Ratio float64
+}
+
+var randFloat64 = rand.Float64
+
+// Sample implements the Sampler interface
+func (s RandomSampler) Sample(level Level, template string) int {
+	if s.Ratio >= 1 {
+		return 1
+	}
+	if s.Ratio <= 0 || randFloat64() >= s.Ratio {
+		return 0
+	}
+	return int(1/s.Ratio + 0.5)
+}
+
+// requestSampler applies a per request sampling decision to the messages below
+// level, keeping all the messages of sampled requests and none of the others.
+type requestSampler struct {
+	keep  bool
+	rate  int
+	level Level
+	next  Sampler
+}
+
+// newRequestSampler decides if the request with the given id is sampled, keeping
+// ratio of the requests. The decision is based on a hash of the id so it is
+// consistent for a given request id. If id is empty, a random decision is taken.
+func newRequestSampler(id string, ratio float64, level Level, next Sampler) requestSampler {
+	s := requestSampler{
+		level: level,
+		next:  next,
+	}
+	switch {
+	case ratio >= 1:
+		s.keep = true
+	case ratio <= 0:
+		s.keep = false
+	case id != "":
+		h := fnv.New32a()
+		h.Write([]byte(id))
+		s.keep = float64(h.Sum32()) < ratio*math.MaxUint32
+	default:
+		s.keep = randFloat64() < ratio
+	}
+	if s.keep && ratio > 0 && ratio < 1 {
+		s.rate = int(1/ratio + 0.5)
+	} else {
+		s.rate = 1
+	}
+	return s
+}
+
+// Sample implements the Sampler interface
+func (s requestSampler) Sample(level Level, template string) int {
+	rate := 1
+	if !level.AtLeast(s.level) {
+		if !s.keep {
+			return 0
+		}
+		rate = s.rate
+	}
+	if s.next != nil {
+		rate *= s.next.Sample(level, template)
+	}
+	return rate
+}
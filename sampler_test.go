@@ -0,0 +1,131 @@
+package xlog
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rs/xid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBurstSampler(t *testing.T) {
+	defer func(n func() time.Time) { now = n }(now)
+	ts := time.Date(2000, 1, 2, 3, 4, 5, 0, time.UTC)
+	now = func() time.Time { return ts }
+	s := &BurstSampler{First: 2, Thereafter: 3}
+	rates := []int{}
+	for i := 0; i < 8; i++ {
+		rates = append(rates, s.Sample(LevelInfo, "test"))
+	}
+	assert.Equal(t, []int{1, 1, 0, 0, 3, 0, 0, 3}, rates)
+	ts = ts.Add(time.Second)
+	assert.Equal(t, 1, s.Sample(LevelInfo, "test"))
+
+	s = &BurstSampler{First: 1, Period: time.Minute}
+	assert.Equal(t, 1, s.Sample(LevelInfo, "test"))
+	assert.Equal(t, 0, s.Sample(LevelInfo, "test"))
+	ts = ts.Add(time.Second)
+	assert.Equal(t, 0, s.Sample(LevelInfo, "test"))
+	ts = ts.Add(time.Minute)
+	assert.Equal(t, 1, s.Sample(LevelInfo, "test"))
+}
+
+func TestTemplateSampler(t *testing.T) {
+	s := &TemplateSampler{First: 1, Thereafter: 2}
+	assert.Equal(t, 1, s.Sample(LevelInfo, "foo %s"))
+	assert.Equal(t, 0, s.Sample(LevelInfo, "foo %s"))
+	assert.Equal(t, 2, s.Sample(LevelInfo, "foo %s"))
+	assert.Equal(t, 1, s.Sample(LevelInfo, "bar %s"))
+	assert.Equal(t, 1, s.Sample(LevelError, "foo %s"))
+}
+
+func TestRandomSampler(t *testing.T) {
+	defer func(r func() float64) { randFloat64 = r }(randFloat64)
+	v := 0.0
+	randFloat64 = func() float64 { return v }
+	s := RandomSampler{Ratio: 0.1}
+	assert.Equal(t, 10, s.Sample(LevelInfo, "test"))
+	v = 0.5
+	assert.Equal(t, 0, s.Sample(LevelInfo, "test"))
+	assert.Equal(t, 1, RandomSampler{Ratio: 1}.Sample(LevelInfo, "test"))
+	assert.Equal(t, 0, RandomSampler{}.Sample(LevelInfo, "test"))
+}
+
+func TestLevelSampler(t *testing.T) {
+	drop := SamplerFunc(func(level Level, template string) int { return 0 })
+	s := LevelSampler{Debug: drop, Info: drop}
+	assert.Equal(t, 0, s.Sample(LevelDebug, "test"))
+	assert.Equal(t, 0, s.Sample(LevelInfo, "test"))
+	assert.Equal(t, 1, s.Sample(LevelWarn, "test"))
+	assert.Equal(t, 1, s.Sample(LevelError, "test"))
+}
+
+func TestSendSampler(t *testing.T) {
+	o := newTestOutput()
+	templates := []string{}
+	l := New(Config{
+		Output: o,
+		Level:  LevelInfo,
+		Sampler: SamplerFunc(func(level Level, template string) int {
+			templates = append(templates, template)
+			if level == LevelWarn {
+				return 0
+			}
+			return 5
+		}),
+	}).(*logger)
+	l.Debug("debug")
+	l.Infof("info %d", 1)
+	last := o.get()
+	assert.Equal(t, "info 1", last["message"])
+	assert.Equal(t, 5, last["sample_rate"])
+	l.Warn("warn")
+	assert.True(t, o.empty())
+	assert.Equal(t, []string{"info %d", "warn"}, templates)
+
+	l = New(Config{Output: o}).(*logger)
+	l.Info("test")
+	assert.NotContains(t, o.get(), "sample_rate")
+}
+
+func TestRequestSampler(t *testing.T) {
+	id := xid.New().String()
+	s := newRequestSampler(id, 0.5, LevelWarn, nil)
+	// The decision is consistent for a given id
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, s, newRequestSampler(id, 0.5, LevelWarn, nil))
+	}
+	if s.keep {
+		assert.Equal(t, 2, s.Sample(LevelInfo, "test"))
+	} else {
+		assert.Equal(t, 0, s.Sample(LevelInfo, "test"))
+	}
+	assert.Equal(t, 1, s.Sample(LevelWarn, "test"))
+	assert.Equal(t, 1, s.Sample(LevelError, "test"))
+
+	s = newRequestSampler(id, 1, LevelWarn, nil)
+	assert.Equal(t, 1, s.Sample(LevelDebug, "test"))
+	s = newRequestSampler(id, 0, LevelWarn, nil)
+	assert.Equal(t, 0, s.Sample(LevelDebug, "test"))
+	assert.Equal(t, 1, s.Sample(LevelWarn, "test"))
+
+	next := SamplerFunc(func(level Level, template string) int { return 3 })
+	s = newRequestSampler(id, 1, LevelWarn, next)
+	assert.Equal(t, 3, s.Sample(LevelDebug, "test"))
+	assert.Equal(t, 3, s.Sample(LevelWarn, "test"))
+
+	// Roughly ratio of the requests are kept
+	kept := 0
+	for i := 0; i < 1000; i++ {
+		if newRequestSampler(xid.New().String(), 0.2, LevelWarn, nil).keep {
+			kept++
+		}
+	}
+	assert.InDelta(t, 200, kept, 100)
+
+	defer func(r func() float64) { randFloat64 = r }(randFloat64)
+	randFloat64 = func() float64 { return 0.1 }
+	assert.True(t, newRequestSampler("", 0.2, LevelWarn, nil).keep)
+	randFloat64 = func() float64 { return 0.3 }
+	assert.False(t, newRequestSampler("", 0.2, LevelWarn, nil).keep)
+}
@@ -0,0 +1,42 @@
+package xlog
+
+import (
+	"runtime"
+	"strconv"
+)
+
+// maxStackDepth is the maximum number of frames reported in a stack trace.
+const maxStackDepth = 32
+
+// Frame is a stack frame as reported in the KeyStack field.
+type Frame struct {
+	Func string `json:"func"`
+	File string `json:"file"`
+	Line int    `json:"line"`
+}
+
+// String returns the frame in the "func (file:line)" form.
+func (f Frame) String() string {
+	return f.Func + " (" + f.File + ":" + strconv.FormatInt(int64(f.Line), 10) + ")"
+}
+
+// stackTrace returns the stack of the calling go routine. The skip argument is
+// the number of frames to skip above the caller of stackTrace.
+func stackTrace(skip int) []Frame {
+	pcs := make([]uintptr, maxStackDepth)
+	// Skip runtime.Callers and stackTrace frames
+	n := runtime.Callers(skip+2, pcs)
+	if n == 0 {
+		return nil
+	}
+	stack := make([]Frame, 0, n)
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		f, more := frames.Next()
+		stack = append(stack, Frame{Func: f.Function, File: f.File, Line: f.Line})
+		if !more {
+			break
+		}
+	}
+	return stack
+}
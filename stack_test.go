@@ -0,0 +1,51 @@
+package xlog
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStackTrace(t *testing.T) {
+	stack := stackTrace(0)
+	if assert.NotEmpty(t, stack) {
+		assert.Equal(t, "github.com/rs/xlog.TestStackTrace", stack[0].Func)
+		assert.Contains(t, stack[0].File, "stack_test.go")
+		assert.NotZero(t, stack[0].Line)
+	}
+	assert.True(t, len(stack) <= maxStackDepth)
+}
+
+func TestFrameString(t *testing.T) {
+	f := Frame{Func: "main.main", File: "/src/main.go", Line: 12}
+	assert.Equal(t, "main.main (/src/main.go:12)", f.String())
+}
+
+func TestSendStackTrace(t *testing.T) {
+	o := newTestOutput()
+	l := New(Config{Output: o, StackTraceLevel: LevelError}).(*logger)
+	l.Warn("test")
+	assert.NotContains(t, o.get(), "stack")
+	l.Error("test")
+	last := o.get()
+	if stack, ok := last["stack"].([]Frame); assert.True(t, ok) && assert.NotEmpty(t, stack) {
+		assert.Equal(t, "github.com/rs/xlog.TestSendStackTrace", stack[0].Func)
+	}
+	l = New(Config{Output: o}).(*logger)
+	l.Error("test")
+	assert.NotContains(t, o.get(), "stack")
+}
+
+func TestConsoleOutputStack(t *testing.T) {
+	buf := &bytes.Buffer{}
+	c := consoleOutput{w: buf}
+	err := c.Write(F{"message": "some error", "level": "error", "foo": "bar", "stack": []Frame{
+		{Func: "main.handler", File: "/src/main.go", Line: 12},
+		{Func: "main.main", File: "/src/main.go", Line: 42},
+	}})
+	assert.NoError(t, err)
+	assert.Equal(t, "\x1b[31mERRO\x1b[0m some error \x1b[32mfoo\x1b[0m=bar\n"+
+		"    main.handler\n        /src/main.go:12\n"+
+		"    main.main\n        /src/main.go:42\n", buf.String())
+}
@@ -0,0 +1,160 @@
+package xlog
+
+import (
+	"expvar"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// latencyWindow is the number of most recent output writes the latency
+// percentiles of OutputChannelStats are computed on.
+const latencyWindow = 1024
+
+// OutputChannelStats holds the statistics of an OutputChannel.
+type OutputChannelStats struct {
+	// QueueLength is the number of messages waiting to be written.
+	QueueLength int `json:"queue_length"`
+	// Capacity is the maximum number of messages queued.
+	Capacity int `json:"capacity"`
+	// Written is the number of messages successfully written to the output.
+	Written uint64 `json:"written"`
+	// Dropped is the number of messages discarded because the buffer was full.
+	Dropped uint64 `json:"dropped"`
+	// Errors is the number of messages the output failed to write. All the
+	// messages of a batch are counted when its write fails.
+	Errors uint64 `json:"errors"`
+	// Writes is the number of writes to the output, a batch counting as one.
+	Writes uint64 `json:"writes"`
+	// WriteTime is the cumulated duration of the output writes.
+	WriteTime time.Duration `json:"write_time"`
+	// LatencyP50, LatencyP90 and LatencyP99 are percentiles of the duration of
+	// the most recent output writes.
+	LatencyP50 time.Duration `json:"latency_p50"`
+	LatencyP90 time.Duration `json:"latency_p90"`
+	LatencyP99 time.Duration `json:"latency_p99"`
+}
+
+// Stats returns the current statistics of the channel.
+func (oc *OutputChannel) Stats() OutputChannelStats {
+	oc.mu.Lock()
+	s := OutputChannelStats{
+		QueueLength: oc.queue.len(),
+		Capacity:    oc.opts.BufferSize,
+		Dropped:     oc.droppedTotal,
+	}
+	oc.mu.Unlock()
+
+	oc.smu.Lock()
+	s.Written = oc.written
+	s.Errors = oc.errors
+	s.WriteTime = oc.writeTime
+	s.Writes = oc.nWrites
+	n := latencyWindow
+	if oc.nWrites < latencyWindow {
+		n = int(oc.nWrites)
+	}
+	latencies := make([]time.Duration, n)
+	copy(latencies, oc.latencies[:n])
+	oc.smu.Unlock()
+
+	if n > 0 {
+		sort.Sort(durations(latencies))
+		s.LatencyP50 = percentile(latencies, 0.5)
+		s.LatencyP90 = percentile(latencies, 0.9)
+		s.LatencyP99 = percentile(latencies, 0.99)
+	}
+	return s
+}
+
+type durations []time.Duration
+
+func (d durations) Len() int           { return len(d) }
+func (d durations) Less(i, j int) bool { return d[i] < d[j] }
+func (d durations) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
+
+// percentile returns the p percentile of the sorted durations d using the
+// nearest rank method.
+func percentile(d []time.Duration, p float64) time.Duration {
+	i := int(p*float64(len(d))+0.5) - 1
+	if i < 0 {
+		i = 0
+	}
+	return d[i]
+}
+
+// PublishExpvar publishes the statistics of the channel as an expvar variable
+// with the given name. Like expvar.Publish, it panics if the name is already
+// registered.
+func (oc *OutputChannel) PublishExpvar(name string) {
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		return oc.Stats()
+	}))
+}
+
+// PrometheusHandler is an http.Handler exposing the statistics of output
+// channels in the Prometheus text exposition format.
+type PrometheusHandler struct {
+	// Channels are the output channels to expose, by name. The name is set as
+	// the channel label of the metrics.
+	Channels map[string]*OutputChannel
+}
+
+// ServeHTTP implements http.Handler interface
+func (h PrometheusHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	names := make([]string, 0, len(h.Channels))
+	for name := range h.Channels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	stats := make([]OutputChannelStats, len(names))
+	for i, name := range names {
+		stats[i] = h.Channels[name].Stats()
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	metric := func(name, typ, help string, value func(s OutputChannelStats) float64) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+		for i, s := range stats {
+			fmt.Fprintf(w, "%s{channel=\"%s\"} %s\n", name, escapeLabel(names[i]), formatFloat(value(s)))
+		}
+	}
+	metric("xlog_output_queue_length", "gauge", "Number of messages waiting to be written.",
+		func(s OutputChannelStats) float64 { return float64(s.QueueLength) })
+	metric("xlog_output_queue_capacity", "gauge", "Maximum number of messages queued.",
+		func(s OutputChannelStats) float64 { return float64(s.Capacity) })
+	metric("xlog_output_written_total", "counter", "Number of messages written to the output.",
+		func(s OutputChannelStats) float64 { return float64(s.Written) })
+	metric("xlog_output_dropped_total", "counter", "Number of messages discarded because the buffer was full.",
+		func(s OutputChannelStats) float64 { return float64(s.Dropped) })
+	metric("xlog_output_errors_total", "counter", "Number of messages the output failed to write.",
+		func(s OutputChannelStats) float64 { return float64(s.Errors) })
+
+	const latency = "xlog_output_write_duration_seconds"
+	fmt.Fprintf(w, "# HELP %s Duration of the output writes.\n# TYPE %s summary\n", latency, latency)
+	for i, s := range stats {
+		l := escapeLabel(names[i])
+		for _, q := range []struct {
+			quantile string
+			d        time.Duration
+		}{{"0.5", s.LatencyP50}, {"0.9", s.LatencyP90}, {"0.99", s.LatencyP99}} {
+			fmt.Fprintf(w, "%s{channel=\"%s\",quantile=\"%s\"} %s\n", latency, l, q.quantile, formatFloat(q.d.Seconds()))
+		}
+		fmt.Fprintf(w, "%s_sum{channel=\"%s\"} %s\n", latency, l, formatFloat(s.WriteTime.Seconds()))
+		fmt.Fprintf(w, "%s_count{channel=\"%s\"} %d\n", latency, l, s.Writes)
+	}
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// escapeLabel escapes a Prometheus label value.
+func escapeLabel(v string) string {
+	return labelEscaper.Replace(v)
+}
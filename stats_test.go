@@ -0,0 +1,89 @@
+package xlog
+
+import (
+	"encoding/json"
+	"errors"
+	"expvar"
+	"io/ioutil"
+	"log"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutputChannelStats(t *testing.T) {
+	oc, o, release := newBlockedOutputChannel(OutputChannelOptions{})
+	defer oc.Close()
+	oc.Write(F{"message": "a"})
+	oc.Write(F{"message": "b"})
+	oc.Write(F{"message": "c"})
+	s := oc.Stats()
+	assert.Equal(t, 2, s.QueueLength)
+	assert.Equal(t, 2, s.Capacity)
+	assert.Equal(t, uint64(1), s.Dropped)
+	assert.Equal(t, uint64(0), s.Written)
+	close(release)
+	for i := 0; i < 4; i++ {
+		o.get()
+	}
+	oc.Flush()
+	s = oc.Stats()
+	assert.Equal(t, 0, s.QueueLength)
+	// first, loss report, a and b
+	assert.Equal(t, uint64(4), s.Written)
+	assert.Equal(t, uint64(0), s.Errors)
+	assert.True(t, s.LatencyP50 > 0)
+	assert.True(t, s.LatencyP99 >= s.LatencyP90)
+	assert.True(t, s.LatencyP90 >= s.LatencyP50)
+	assert.True(t, s.WriteTime >= s.LatencyP99)
+
+	critialLoggerMux.Lock()
+	defer critialLoggerMux.Unlock()
+	oldCritialLogger := critialLogger
+	critialLogger = log.New(ioutil.Discard, "", 0)
+	oc = NewOutputChannel(newTestOutputErr(errors.New("some error")))
+	oc.Write(F{})
+	oc.Close()
+	critialLogger = oldCritialLogger
+	assert.Equal(t, uint64(1), oc.Stats().Errors)
+}
+
+func TestPercentile(t *testing.T) {
+	d := []time.Duration{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	assert.Equal(t, time.Duration(5), percentile(d, 0.5))
+	assert.Equal(t, time.Duration(9), percentile(d, 0.9))
+	assert.Equal(t, time.Duration(10), percentile(d, 0.99))
+	assert.Equal(t, time.Duration(1), percentile(d[:1], 0.5))
+}
+
+func TestPublishExpvar(t *testing.T) {
+	oc := NewOutputChannelBuffer(Discard, 10)
+	defer oc.Close()
+	// expvar names can't be reused when the test is run several times
+	name := "xlog_test_output_" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	oc.PublishExpvar(name)
+	s := OutputChannelStats{}
+	assert.NoError(t, json.Unmarshal([]byte(expvar.Get(name).String()), &s))
+	assert.Equal(t, 10, s.Capacity)
+}
+
+func TestPrometheusHandler(t *testing.T) {
+	oc := NewOutputChannelBuffer(Discard, 10)
+	defer oc.Close()
+	oc.Write(F{})
+	oc.Flush()
+	h := PrometheusHandler{Channels: map[string]*OutputChannel{`ma"in`: oc}}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", w.Header().Get("Content-Type"))
+	body := w.Body.String()
+	assert.Contains(t, body, "# TYPE xlog_output_queue_capacity gauge\nxlog_output_queue_capacity{channel=\"ma\\\"in\"} 10\n")
+	assert.Contains(t, body, "# TYPE xlog_output_written_total counter\nxlog_output_written_total{channel=\"ma\\\"in\"} 1\n")
+	assert.Contains(t, body, "xlog_output_dropped_total{channel=\"ma\\\"in\"} 0\n")
+	assert.Contains(t, body, "# TYPE xlog_output_write_duration_seconds summary\n")
+	assert.Contains(t, body, "xlog_output_write_duration_seconds{channel=\"ma\\\"in\",quantile=\"0.99\"} ")
+	assert.Contains(t, body, "xlog_output_write_duration_seconds_count{channel=\"ma\\\"in\"} 1\n")
+}
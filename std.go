@@ -11,6 +11,18 @@ func SetLogger(logger Logger) {
 	std = logger
 }
 
+// Trace calls the Trace() method on the default logger
+func Trace(v ...interface{}) {
+	f := extractFields(&v)
+	std.OutputF(LevelTrace, 2, fmt.Sprint(v...), f)
+}
+
+// Tracef calls the Tracef() method on the default logger
+func Tracef(format string, v ...interface{}) {
+	f := extractFields(&v)
+	std.OutputF(LevelTrace, 2, fmt.Sprintf(format, v...), f)
+}
+
 // Debug calls the Debug() method on the default logger
 func Debug(v ...interface{}) {
 	f := extractFields(&v)
@@ -35,6 +47,18 @@ func Infof(format string, v ...interface{}) {
 	std.OutputF(LevelInfo, 2, fmt.Sprintf(format, v...), f)
 }
 
+// Notice calls the Notice() method on the default logger
+func Notice(v ...interface{}) {
+	f := extractFields(&v)
+	std.OutputF(LevelNotice, 2, fmt.Sprint(v...), f)
+}
+
+// Noticef calls the Noticef() method on the default logger
+func Noticef(format string, v ...interface{}) {
+	f := extractFields(&v)
+	std.OutputF(LevelNotice, 2, fmt.Sprintf(format, v...), f)
+}
+
 // Warn calls the Warn() method on the default logger
 func Warn(v ...interface{}) {
 	f := extractFields(&v)
@@ -69,6 +93,28 @@ func Errorf(format string, v ...interface{}) {
 	std.OutputF(LevelError, 2, fmt.Sprintf(format, v...), f)
 }
 
+// Critical calls the Critical() method on the default logger
+func Critical(v ...interface{}) {
+	f := extractFields(&v)
+	std.OutputF(LevelCritical, 2, fmt.Sprint(v...), f)
+}
+
+// Criticalf calls the Criticalf() method on the default logger
+//
+// Go vet users: you may append %v at the end of you format when using xlog.F{} as a last
+// argument to workaround go vet false alarm.
+func Criticalf(format string, v ...interface{}) {
+	f := extractFields(&v)
+	if f != nil {
+		// Let user add a %v at the end of the message when fields are passed to satisfy go vet
+		l := len(format)
+		if l > 2 && format[l-2] == '%' && format[l-1] == 'v' {
+			format = format[0 : l-2]
+		}
+	}
+	std.OutputF(LevelCritical, 2, fmt.Sprintf(format, v...), f)
+}
+
 // Fatal calls the Fatal() method on the default logger
 func Fatal(v ...interface{}) {
 	f := extractFields(&v)
@@ -102,3 +148,39 @@ func Fatalf(format string, v ...interface{}) {
 	}
 	exit1()
 }
+
+// Panic calls the Panic() method on the default logger
+func Panic(v ...interface{}) {
+	f := extractFields(&v)
+	msg := fmt.Sprint(v...)
+	std.OutputF(LevelPanic, 2, msg, f)
+	if l, ok := std.(*logger); ok {
+		if o, ok := l.output.(*OutputChannel); ok {
+			o.Flush()
+		}
+	}
+	panic(msg)
+}
+
+// Panicf calls the Panicf() method on the default logger
+//
+// Go vet users: you may append %v at the end of you format when using xlog.F{} as a last
+// argument to workaround go vet false alarm.
+func Panicf(format string, v ...interface{}) {
+	f := extractFields(&v)
+	if f != nil {
+		// Let user add a %v at the end of the message when fields are passed to satisfy go vet
+		l := len(format)
+		if l > 2 && format[l-2] == '%' && format[l-1] == 'v' {
+			format = format[0 : l-2]
+		}
+	}
+	msg := fmt.Sprintf(format, v...)
+	std.OutputF(LevelPanic, 2, msg, f)
+	if l, ok := std.(*logger); ok {
+		if o, ok := l.output.(*OutputChannel); ok {
+			o.Flush()
+		}
+	}
+	panic(msg)
+}
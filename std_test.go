@@ -10,10 +10,20 @@ func TestGlobalLogger(t *testing.T) {
 	o := newTestOutput()
 	oldStd := std
 	defer func() { std = oldStd }()
-	SetLogger(New(Config{Output: o}))
-	Debug("test")
+	SetLogger(New(Config{Output: o, Level: LevelTrace}))
+	Trace("test")
 	last := o.get()
 	assert.Equal(t, "test", last["message"])
+	assert.Equal(t, "trace", last["level"])
+	o.reset()
+	Tracef("test")
+	last = o.get()
+	assert.Equal(t, "test", last["message"])
+	assert.Equal(t, "trace", last["level"])
+	o.reset()
+	Debug("test")
+	last = o.get()
+	assert.Equal(t, "test", last["message"])
 	assert.Equal(t, "debug", last["level"])
 	o.reset()
 	Debugf("test")
@@ -31,6 +41,16 @@ func TestGlobalLogger(t *testing.T) {
 	assert.Equal(t, "test", last["message"])
 	assert.Equal(t, "info", last["level"])
 	o.reset()
+	Notice("test")
+	last = o.get()
+	assert.Equal(t, "test", last["message"])
+	assert.Equal(t, "notice", last["level"])
+	o.reset()
+	Noticef("test")
+	last = o.get()
+	assert.Equal(t, "test", last["message"])
+	assert.Equal(t, "notice", last["level"])
+	o.reset()
 	Warn("test")
 	last = o.get()
 	assert.Equal(t, "test", last["message"])
@@ -51,6 +71,27 @@ func TestGlobalLogger(t *testing.T) {
 	assert.Equal(t, "test", last["message"])
 	assert.Equal(t, "error", last["level"])
 	o.reset()
+	Critical("test")
+	last = o.get()
+	assert.Equal(t, "test", last["message"])
+	assert.Equal(t, "critical", last["level"])
+	o.reset()
+	Criticalf("test")
+	last = o.get()
+	assert.Equal(t, "test", last["message"])
+	assert.Equal(t, "critical", last["level"])
+	o.reset()
+	assert.PanicsWithValue(t, "test", func() { Panic("test") })
+	last = o.get()
+	assert.Equal(t, "test", last["message"])
+	assert.Equal(t, "panic", last["level"])
+	o.reset()
+	assert.PanicsWithValue(t, "test 1", func() { Panicf("test %d%v", 1, F{"foo": "bar"}) })
+	last = o.get()
+	assert.Equal(t, "test 1", last["message"])
+	assert.Equal(t, "panic", last["level"])
+	assert.Equal(t, "bar", last["foo"])
+	o.reset()
 	oldExit := exit1
 	exit1 = func() {}
 	defer func() { exit1 = oldExit }()
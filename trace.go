@@ -0,0 +1,226 @@
+package xlog
+
+import (
+	crand "crypto/rand"
+	"encoding/hex"
+	"math/rand"
+	"net/http"
+	"strings"
+)
+
+// TraceID is a W3C Trace Context / B3 128-bit trace id.
+type TraceID [16]byte
+
+// String returns the id as 32 lowercase hex characters.
+func (id TraceID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// IsValid returns true if the id is not all zeros.
+func (id TraceID) IsValid() bool {
+	return id != TraceID{}
+}
+
+// SpanID is a W3C Trace Context / B3 64-bit span id.
+type SpanID [8]byte
+
+// String returns the id as 16 lowercase hex characters.
+func (id SpanID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// IsValid returns true if the id is not all zeros.
+func (id SpanID) IsValid() bool {
+	return id != SpanID{}
+}
+
+// TraceContext is the distributed tracing context of a request as set by
+// TraceHandler.
+type TraceContext struct {
+	// TraceID identifies the trace the request is part of.
+	TraceID TraceID
+	// SpanID identifies the handling of the request by the service.
+	SpanID SpanID
+	// ParentSpanID is the span id propagated by the caller, if any.
+	ParentSpanID SpanID
+	// Sampled reports if the caller recorded the trace.
+	Sampled bool
+	// TraceState is the vendor specific W3C tracestate header propagated
+	// along with a valid traceparent header.
+	TraceState string
+}
+
+// Trace context headers
+const (
+	headerTraceParent = "Traceparent"
+	headerTraceState  = "Tracestate"
+	headerB3          = "B3"
+	headerB3TraceID   = "X-B3-Traceid"
+	headerB3SpanID    = "X-B3-Spanid"
+	headerB3Sampled   = "X-B3-Sampled"
+	headerB3Flags     = "X-B3-Flags"
+)
+
+// newTraceContext returns the trace context of a request with header h. The
+// W3C traceparent header is used first, then the B3 single header and finally
+// the B3 multiple headers. A new trace id is generated if none is found and a
+// new span id is always generated for the request, the span id received being
+// reported as parent.
+func newTraceContext(h http.Header) TraceContext {
+	tc, ok := parseTraceParent(h.Get(headerTraceParent))
+	if ok {
+		tc.TraceState = h.Get(headerTraceState)
+	} else if b3 := h.Get(headerB3); b3 != "" {
+		tc, ok = parseB3(b3)
+	} else {
+		tc, ok = parseB3Multi(h)
+	}
+	if !ok {
+		tc = TraceContext{}
+	}
+	if !tc.TraceID.IsValid() {
+		randomID(tc.TraceID[:])
+	}
+	randomID(tc.SpanID[:])
+	return tc
+}
+
+// parseTraceParent parses a W3C traceparent header:
+//
+//	{version}-{trace-id}-{parent-id}-{trace-flags}
+//
+// Future versions are accepted as long as they start with the fields of version
+// 00.
+func parseTraceParent(v string) (tc TraceContext, ok bool) {
+	if len(v) < 55 || v[2] != '-' || v[35] != '-' || v[52] != '-' {
+		return tc, false
+	}
+	version, ok := decodeHex(v[:2])
+	if !ok || version[0] == 0xff || (version[0] == 0 && len(v) != 55) || (len(v) > 55 && v[55] != '-') {
+		return tc, false
+	}
+	flags, ok := decodeHex(v[53:55])
+	if !ok ||
+		!decodeID(tc.TraceID[:], v[3:35]) || !tc.TraceID.IsValid() ||
+		!decodeID(tc.ParentSpanID[:], v[36:52]) || !tc.ParentSpanID.IsValid() {
+		return TraceContext{}, false
+	}
+	tc.Sampled = flags[0]&1 == 1
+	return tc, true
+}
+
+// parseB3 parses a B3 single header:
+//
+//	{TraceId}-{SpanId}-{SamplingState}-{ParentSpanId}
+//
+// where the last two fields are optional. The header may also only hold the
+// sampling state.
+func parseB3(v string) (tc TraceContext, ok bool) {
+	parts := strings.Split(v, "-")
+	if len(parts) == 1 {
+		tc.Sampled, ok = parseB3Sampled(parts[0])
+		return tc, ok
+	}
+	if len(parts) > 4 ||
+		!decodeTraceID(&tc.TraceID, parts[0]) ||
+		!decodeID(tc.ParentSpanID[:], parts[1]) || !tc.ParentSpanID.IsValid() {
+		return TraceContext{}, false
+	}
+	if len(parts) > 2 {
+		if tc.Sampled, ok = parseB3Sampled(parts[2]); !ok {
+			return TraceContext{}, false
+		}
+	}
+	return tc, true
+}
+
+// parseB3Multi parses the B3 multiple headers.
+func parseB3Multi(h http.Header) (tc TraceContext, ok bool) {
+	tid, sid := h.Get(headerB3TraceID), h.Get(headerB3SpanID)
+	if tid == "" || sid == "" ||
+		!decodeTraceID(&tc.TraceID, tid) ||
+		!decodeID(tc.ParentSpanID[:], sid) || !tc.ParentSpanID.IsValid() {
+		return TraceContext{}, false
+	}
+	switch h.Get(headerB3Sampled) {
+	case "1", "true":
+		tc.Sampled = true
+	}
+	if h.Get(headerB3Flags) == "1" {
+		tc.Sampled = true
+	}
+	return tc, true
+}
+
+// parseB3Sampled parses a B3 sampling state, "d" meaning debug.
+func parseB3Sampled(v string) (sampled, ok bool) {
+	switch v {
+	case "1", "d":
+		return true, true
+	case "0":
+		return false, true
+	}
+	return false, false
+}
+
+// decodeTraceID decodes a 64 or 128-bit B3 trace id into id, left padding 64-bit
+// ids with zeros.
+func decodeTraceID(id *TraceID, v string) bool {
+	*id = TraceID{}
+	dst := id[:]
+	if len(v) == 16 {
+		dst = id[8:]
+	}
+	return decodeID(dst, v) && id.IsValid()
+}
+
+// decodeID decodes the lowercase hex id v into dst, which must be exactly half
+// its length.
+func decodeID(dst []byte, v string) bool {
+	if len(v) != 2*len(dst) {
+		return false
+	}
+	b, ok := decodeHex(v)
+	if !ok {
+		return false
+	}
+	copy(dst, b)
+	return true
+}
+
+// decodeHex decodes lowercase hex strings, rejecting uppercase ones as required
+// by the W3C specification.
+func decodeHex(v string) ([]byte, bool) {
+	if strings.ToLower(v) != v {
+		return nil, false
+	}
+	b, err := hex.DecodeString(v)
+	return b, err == nil
+}
+
+// randomID fills id with random bytes, making sure it is not all zeros.
+func randomID(id []byte) {
+	for {
+		if _, err := crand.Read(id); err != nil {
+			for i := range id {
+				id[i] = byte(rand.Intn(256))
+			}
+		}
+		for _, b := range id {
+			if b != 0 {
+				return
+			}
+		}
+	}
+}
+
+// setTraceFields adds the trace and span ids of tc to l using the given field
+// names, if not empty.
+func setTraceFields(l Logger, tc TraceContext, traceName, spanName string) {
+	if traceName != "" {
+		l.SetField(traceName, tc.TraceID.String())
+	}
+	if spanName != "" {
+		l.SetField(spanName, tc.SpanID.String())
+	}
+}
@@ -0,0 +1,60 @@
+package xlog
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewTraceContext(t *testing.T) {
+	tests := []struct {
+		header  http.Header
+		ok      bool
+		traceID string
+		parent  string
+		sampled bool
+		state   string
+	}{
+		{http.Header{}, false, "", "0000000000000000", false, ""},
+		{http.Header{"Traceparent": {"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}, "Tracestate": {"congo=t61rcWkgMzE"}},
+			true, "4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7", true, "congo=t61rcWkgMzE"},
+		{http.Header{"Traceparent": {"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00"}},
+			true, "4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7", false, ""},
+		// Future version with extra fields
+		{http.Header{"Traceparent": {"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra"}},
+			true, "4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7", true, ""},
+		// Invalid traceparent headers, tracestate is ignored
+		{http.Header{"Traceparent": {"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra"}, "Tracestate": {"congo=t61rcWkgMzE"}},
+			false, "", "0000000000000000", false, ""},
+		{http.Header{"Traceparent": {"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}}, false, "", "0000000000000000", false, ""},
+		{http.Header{"Traceparent": {"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01"}}, false, "", "0000000000000000", false, ""},
+		{http.Header{"Traceparent": {"00-00000000000000000000000000000000-00f067aa0ba902b7-01"}}, false, "", "0000000000000000", false, ""},
+		{http.Header{"Traceparent": {"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01"}}, false, "", "0000000000000000", false, ""},
+		// B3 single header
+		{http.Header{"B3": {"80f198ee56343ba864fe8b2a57d3eff7-e457b5a2e4d86bd1-1-05e3ac9a4f6e3b90"}},
+			true, "80f198ee56343ba864fe8b2a57d3eff7", "e457b5a2e4d86bd1", true, ""},
+		{http.Header{"B3": {"64fe8b2a57d3eff7-e457b5a2e4d86bd1"}},
+			true, "000000000000000064fe8b2a57d3eff7", "e457b5a2e4d86bd1", false, ""},
+		{http.Header{"B3": {"d"}}, true, "", "0000000000000000", true, ""},
+		{http.Header{"B3": {"64fe8b2a57d3eff7-e457b5a2e4d86bd1-x"}}, false, "", "0000000000000000", false, ""},
+		// B3 multiple headers
+		{http.Header{"X-B3-Traceid": {"80f198ee56343ba864fe8b2a57d3eff7"}, "X-B3-Spanid": {"e457b5a2e4d86bd1"}, "X-B3-Sampled": {"1"}},
+			true, "80f198ee56343ba864fe8b2a57d3eff7", "e457b5a2e4d86bd1", true, ""},
+		{http.Header{"X-B3-Traceid": {"64fe8b2a57d3eff7"}, "X-B3-Spanid": {"e457b5a2e4d86bd1"}, "X-B3-Flags": {"1"}},
+			true, "000000000000000064fe8b2a57d3eff7", "e457b5a2e4d86bd1", true, ""},
+		{http.Header{"X-B3-Traceid": {"64fe8b2a57d3eff7"}}, false, "", "0000000000000000", false, ""},
+	}
+	for _, tt := range tests {
+		tc := newTraceContext(tt.header)
+		assert.True(t, tc.TraceID.IsValid(), "%v", tt.header)
+		assert.True(t, tc.SpanID.IsValid(), "%v", tt.header)
+		if tt.traceID != "" {
+			assert.Equal(t, tt.traceID, tc.TraceID.String(), "%v", tt.header)
+		}
+		assert.Equal(t, tt.parent, tc.ParentSpanID.String(), "%v", tt.header)
+		assert.NotEqual(t, tc.ParentSpanID, tc.SpanID, "%v", tt.header)
+		assert.Equal(t, tt.sampled, tc.Sampled, "%v", tt.header)
+		assert.Equal(t, tt.state, tc.TraceState, "%v", tt.header)
+	}
+}
@@ -14,6 +14,7 @@ const (
 	green  color = 32
 	yellow color = 33
 	blue   color = 34
+	cyan   color = 36
 	gray   color = 37
 )
 
@@ -42,6 +43,8 @@ func writeValue(w io.Writer, v interface{}) (err error) {
 		} else {
 			_, err = w.Write([]byte(v))
 		}
+	case LogValuer:
+		err = writeValue(w, v.LogValue())
 	case error:
 		s := v.Error()
 		err = writeValue(w, s)
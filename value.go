@@ -0,0 +1,96 @@
+package xlog
+
+// LogValuer is implemented by types controlling how they are logged when set as
+// a field value, i.e. to hide secrets or render domain types in a readable way.
+// LogValue may return any loggable value, including an F to log the value as a
+// group of fields.
+//
+// LogValue is called by the outputs, in the OutputChannel's go routine when one
+// is used, so its cost is only paid for messages actually emitted. It must thus
+// be safe to call concurrently with the code owning the value.
+type LogValuer interface {
+	LogValue() interface{}
+}
+
+// maxResolveDepth limits the resolution of LogValuers returning other
+// LogValuers or groups of fields containing LogValuers.
+const maxResolveDepth = 8
+
+// resolveFields returns fields with LogValuer and error values replaced by their
+// loggable representation. With flat set to true, groups of fields returned by
+// a LogValuer and structured errors are flattened using dotted keys (i.e.:
+// user.id, err.cause) for line based outputs like logfmt. The fields map is never
+// modified as it may be shared with other outputs; it is returned as is if no
+// value needs to be resolved.
+func resolveFields(fields map[string]interface{}, flat bool) map[string]interface{} {
+	return resolveFieldsDepth(fields, flat, 0)
+}
+
+func resolveFieldsDepth(fields map[string]interface{}, flat bool, depth int) map[string]interface{} {
+	needed := false
+	for _, v := range fields {
+		if needsResolve(v) {
+			needed = true
+			break
+		}
+	}
+	if !needed {
+		return fields
+	}
+	resolved := make(map[string]interface{}, len(fields))
+	for k, v := range fields {
+		resolveField(resolved, k, v, flat, depth)
+	}
+	return resolved
+}
+
+func needsResolve(v interface{}) bool {
+	switch v.(type) {
+	case LogValuer, error:
+		return true
+	}
+	return false
+}
+
+// resolveField sets the resolved value of v as key in dst.
+func resolveField(dst map[string]interface{}, key string, v interface{}, flat bool, depth int) {
+	if depth >= maxResolveDepth {
+		dst[key] = v
+		return
+	}
+	if lv, ok := v.(LogValuer); ok {
+		v = lv.LogValue()
+		var group map[string]interface{}
+		switch g := v.(type) {
+		case F:
+			group = g
+		case map[string]interface{}:
+			group = g
+		default:
+			resolveField(dst, key, v, flat, depth+1)
+			return
+		}
+		if flat {
+			for gk, gv := range group {
+				resolveField(dst, key+"."+gk, gv, flat, depth+1)
+			}
+		} else {
+			dst[key] = resolveFieldsDepth(group, flat, depth+1)
+		}
+		return
+	}
+	if err, ok := v.(error); ok {
+		if isNilError(err) {
+			dst[key] = nil
+			return
+		}
+		ev := errorValue(err, 0)
+		if flat {
+			flattenErrorValue(dst, key, ev)
+		} else {
+			dst[key] = ev
+		}
+		return
+	}
+	dst[key] = v
+}
@@ -0,0 +1,115 @@
+package xlog
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testUser struct {
+	id       int
+	password string
+}
+
+func (u testUser) LogValue() interface{} {
+	return F{"id": u.id}
+}
+
+type testSecret string
+
+func (s testSecret) LogValue() interface{} {
+	return "***"
+}
+
+type testLoop struct{}
+
+func (l testLoop) LogValue() interface{} {
+	return l
+}
+
+type testErrValuer struct{}
+
+func (testErrValuer) LogValue() interface{} {
+	return errors.New("some error")
+}
+
+func TestResolveFields(t *testing.T) {
+	fields := map[string]interface{}{"foo": "bar"}
+	assert.Equal(t, fields, resolveFields(fields, false))
+
+	fields = map[string]interface{}{
+		"foo":      "bar",
+		"user":     testUser{id: 1, password: "secret"},
+		"password": testSecret("secret"),
+		"err":      testErrValuer{},
+	}
+	assert.Equal(t, map[string]interface{}{
+		"foo":      "bar",
+		"user":     map[string]interface{}{"id": 1},
+		"password": "***",
+		"err": map[string]interface{}{
+			"message": "some error",
+			"type":    "*errors.errorString",
+		},
+	}, resolveFields(fields, false))
+	assert.Equal(t, map[string]interface{}{
+		"foo":      "bar",
+		"user.id":  1,
+		"password": "***",
+		"err":      "some error",
+		"err.type": "*errors.errorString",
+	}, resolveFields(fields, true))
+	// Original fields are not modified
+	assert.Equal(t, testSecret("secret"), fields["password"])
+}
+
+func TestResolveFieldsNested(t *testing.T) {
+	fields := map[string]interface{}{
+		"nested": testNested{},
+	}
+	assert.Equal(t, map[string]interface{}{
+		"nested": map[string]interface{}{
+			"password": "***",
+			"user":     map[string]interface{}{"id": 2},
+		},
+	}, resolveFields(fields, false))
+	assert.Equal(t, map[string]interface{}{
+		"nested.password": "***",
+		"nested.user.id":  2,
+	}, resolveFields(fields, true))
+}
+
+type testNested struct{}
+
+func (testNested) LogValue() interface{} {
+	return F{"password": testSecret("secret"), "user": testUser{id: 2}}
+}
+
+func TestResolveFieldsLoop(t *testing.T) {
+	fields := map[string]interface{}{"loop": testLoop{}}
+	assert.Equal(t, map[string]interface{}{"loop": testLoop{}}, resolveFields(fields, false))
+}
+
+func TestWriteValueLogValuer(t *testing.T) {
+	buf := &bytes.Buffer{}
+	assert.NoError(t, writeValue(buf, testSecret("secret")))
+	assert.Equal(t, "***", buf.String())
+}
+
+func TestOutputsLogValuer(t *testing.T) {
+	fields := F{"message": "some message", "user": testUser{id: 1, password: "secret"}}
+	buf := &bytes.Buffer{}
+	assert.NoError(t, NewJSONOutput(buf).Write(fields))
+	assert.Equal(t, `{"message":"some message","user":{"id":1}}`+"\n", buf.String())
+	buf.Reset()
+	assert.NoError(t, NewLogstashOutput(buf).Write(fields))
+	assert.Equal(t, `{"@version":1,"message":"some message","user":{"id":1}}`, buf.String())
+	buf.Reset()
+	assert.NoError(t, NewLogfmtOutput(buf).Write(fields))
+	assert.Equal(t, "level=null message=\"some message\" time=null user.id=1\n", buf.String())
+	buf.Reset()
+	assert.NoError(t, consoleOutput{w: buf}.Write(fields))
+	assert.Equal(t, "some message \x1b[32muser.id\x1b[0m=1\n", buf.String())
+}
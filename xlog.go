@@ -10,12 +10,12 @@
 //
 //     - Per request log context
 //     - Per request and/or per message key/value fields
-//     - Log levels (Debug, Info, Warn, Error)
+//     - Log levels (Trace, Debug, Info, Notice, Warn, Error, Critical, Fatal, Panic)
 //     - Color output when terminal is detected
 //     - Custom output (JSON, logfmt, …)
 //     - Automatic gathering of request context like User-Agent, IP etc.
 //     - Drops message rather than blocking execution
-//     - Easy access logging thru github.com/rs/xaccess
+//     - Built-in access logging
 //
 // It works best in combination with github.com/rs/xhandler.
 package xlog // import "github.com/rs/xlog"
@@ -43,6 +43,19 @@ type Logger interface {
 	SetField(name string, value interface{})
 	// GetFields returns all the fields set on the logger
 	GetFields() F
+	// With returns a new logger sharing the logger's output and level with the
+	// passed fields added to its context. The fields of the original logger are
+	// left untouched.
+	With(fields F) Logger
+	// Enabled returns true if a message with the given level would be output by
+	// the logger. Use it to guard expensive blocks of code only needed for logging.
+	Enabled(level Level) bool
+	// Trace logs a trace message. If last parameter is a map[string]string, it's content
+	// is added as fields to the message.
+	Trace(v ...interface{})
+	// Tracef logs a trace message with format. If last parameter is a map[string]string,
+	// it's content is added as fields to the message.
+	Tracef(format string, v ...interface{})
 	// Debug logs a debug message. If last parameter is a map[string]string, it's content
 	// is added as fields to the message.
 	Debug(v ...interface{})
@@ -55,6 +68,12 @@ type Logger interface {
 	// Info logs a info message with format. If last parameter is a map[string]string,
 	// it's content is added as fields to the message.
 	Infof(format string, v ...interface{})
+	// Notice logs a notice message. If last parameter is a map[string]string, it's content
+	// is added as fields to the message.
+	Notice(v ...interface{})
+	// Noticef logs a notice message with format. If last parameter is a map[string]string,
+	// it's content is added as fields to the message.
+	Noticef(format string, v ...interface{})
 	// Warn logs a warning message. If last parameter is a map[string]string, it's content
 	// is added as fields to the message.
 	Warn(v ...interface{})
@@ -67,12 +86,25 @@ type Logger interface {
 	// Error logs an error message with format. If last parameter is a map[string]string,
 	// it's content is added as fields to the message.
 	Errorf(format string, v ...interface{})
+	// Critical logs a critical message. If last parameter is a map[string]string, it's content
+	// is added as fields to the message.
+	Critical(v ...interface{})
+	// Criticalf logs a critical message with format. If last parameter is a map[string]string,
+	// it's content is added as fields to the message.
+	Criticalf(format string, v ...interface{})
 	// Fatal logs an error message followed by a call to os.Exit(1). If last parameter is a
 	// map[string]string, it's content is added as fields to the message.
 	Fatal(v ...interface{})
 	// Fatalf logs an error message with format followed by a call to ox.Exit(1). If last
 	// parameter is a map[string]string, it's content is added as fields to the message.
 	Fatalf(format string, v ...interface{})
+	// Panic logs a panic message followed by a call to panic() with the message. If last
+	// parameter is a map[string]string, it's content is added as fields to the message.
+	Panic(v ...interface{})
+	// Panicf logs a panic message with format followed by a call to panic() with the
+	// message. If last parameter is a map[string]string, it's content is added as fields
+	// to the message.
+	Panicf(format string, v ...interface{})
 	// Output mimics std logger interface
 	Output(calldepth int, s string) error
 	// OutputF outputs message with fields.
@@ -87,8 +119,15 @@ type LoggerCopier interface {
 
 // Config defines logger's configuration
 type Config struct {
-	// Level is the maximum level to output, logs with lower level are discarded.
-	Level Level
+	// Level is the minimum level to output, logs with lower level are discarded.
+	// Use a *LevelVar to be able to change the level at runtime. If not set,
+	// LevelDebug is used.
+	Level Leveler
+	// ComponentLevels overrides Level for messages with a KeyComponent field
+	// matching one of its keys. Components are hierarchical: a "db.pool" component
+	// inherits the level of "db" unless it has its own entry. Use *LevelVar values
+	// to be able to change those levels at runtime.
+	ComponentLevels map[string]Leveler
 	// Fields defines default fields to use with all messages.
 	Fields map[string]interface{}
 	// Output to use to write log messages to.
@@ -101,16 +140,79 @@ type Config struct {
 	// puts a greater pressure on GC and increases the amount of memory allocated
 	// and freed. Use only if persistent loggers are a requirement.
 	DisablePooling bool
+	// Caller defines how the caller of the log command is reported in the KeyFile
+	// field. By default, the file name and line are reported (i.e.: main.go:12).
+	// CallerFull reports the path relative to the root of the main module.
+	Caller CallerMode
+	// CallerFunc adds the fully qualified name of the function calling the log
+	// command in the KeyFunc field (i.e.: github.com/rs/xlog.TestInfo).
+	CallerFunc bool
+	// StackTraceLevel is the minimum level for which the stack trace of the log
+	// command is added to the message in the KeyStack field as a []Frame. Stack
+	// traces are disabled if not set.
+	StackTraceLevel Leveler
+	// Sampler, if set, is consulted for every message passing the level check to
+	// decide if it is output. Messages output with a sampling rate greater than
+	// one are annotated with the KeySampleRate field.
+	Sampler Sampler
+	// BufferLevel enables the "fingers crossed" mode of the request loggers
+	// created by NewHandler: messages below this level are kept in memory and
+	// only output if a message at or above LevelError is logged during the
+	// request. The buffered messages of requests without error are discarded.
+	BufferLevel Leveler
+	// BufferSize is the maximum number of messages buffered per request when
+	// BufferLevel is set, the oldest messages being discarded first. Defaults
+	// to 100.
+	BufferSize int
+	// CanonicalLine makes the request loggers created by NewHandler output a
+	// summary message at the end of each request. This "canonical log line"
+	// holds all the fields set on the request logger along with the KeyStatus,
+	// KeySize, KeyDuration and KeyMessages fields. It is output at LevelInfo
+	// regardless of the level, sampling and buffering settings.
+	CanonicalLine bool
 }
 
+// CallerMode defines how the caller of a log command is reported.
+type CallerMode int
+
+// Caller modes
+const (
+	// CallerShort reports the file name and line of the caller (i.e.: main.go:12).
+	CallerShort CallerMode = iota
+	// CallerDisabled does not report the caller, saving the cost of its lookup.
+	CallerDisabled
+	// CallerFull reports the file path relative to the root of the main module
+	// (i.e.: cmd/server/main.go:12). Files of other modules are prefixed by
+	// the import path of their package (i.e.: github.com/rs/xlog/xlog.go:12),
+	// like all files when the main module is unknown (before Go 1.12 or outside
+	// of module mode).
+	CallerFull
+)
+
 // F represents a set of log message fields
 type F map[string]interface{}
 
+// Lazy is a field value computed only if the message is actually output, after the
+// level check. Use it for fields expensive to compute:
+//
+//	l.Debug("request", xlog.F{"dump": xlog.Lazy(func() interface{} {
+//		return dump(req)
+//	})})
+type Lazy func() interface{}
+
 type logger struct {
-	level          Level
-	output         Output
-	fields         F
-	disablePooling bool
+	level           Leveler
+	componentLevels map[string]Leveler
+	output          Output
+	fields          F
+	disablePooling  bool
+	caller          CallerMode
+	callerFunc      bool
+	callerSkip      int
+	stackTraceLevel Leveler
+	sampler         Sampler
+	buffer          *requestBuffer
+	counts          *levelCounts
 }
 
 // Common field names for log messages.
@@ -119,6 +221,27 @@ var (
 	KeyMessage = "message"
 	KeyLevel   = "level"
 	KeyFile    = "file"
+	KeyFunc    = "func"
+	KeyStack   = "stack"
+	// KeySampleRate is the field set with the sampling rate of sampled messages.
+	KeySampleRate = "sample_rate"
+	// KeyRepeated is the field set by DedupOutput with the number of duplicates
+	// suppressed.
+	KeyRepeated = "repeated"
+	// KeyDropped is the field set by OutputChannel with the number of messages
+	// dropped in its loss reports.
+	KeyDropped = "dropped"
+	// KeyComponent is the field used to lookup Config.ComponentLevels.
+	KeyComponent = "component"
+	// KeyStatus, KeySize and KeyDuration are the fields set with the status code,
+	// the number of bytes written and the duration of a request by the access
+	// log and the canonical log line.
+	KeyStatus   = "status"
+	KeySize     = "size"
+	KeyDuration = "duration"
+	// KeyMessages is the field of the canonical log line set with the number of
+	// messages logged per level during the request.
+	KeyMessages = "messages"
 )
 
 var now = time.Now
@@ -144,6 +267,10 @@ func New(c Config) Logger {
 		l = loggerPool.Get().(*logger)
 	}
 	l.level = c.Level
+	if l.level == nil {
+		l.level = LevelDebug
+	}
+	l.componentLevels = c.ComponentLevels
 	l.output = c.Output
 	if l.output == nil {
 		l.output = NewOutputChannel(NewConsoleOutput())
@@ -152,6 +279,10 @@ func New(c Config) Logger {
 		l.SetField(k, v)
 	}
 	l.disablePooling = c.DisablePooling
+	l.caller = c.Caller
+	l.callerFunc = c.CallerFunc
+	l.stackTraceLevel = c.StackTraceLevel
+	l.sampler = c.Sampler
 	return l
 }
 
@@ -164,14 +295,39 @@ func Copy(l Logger) Logger {
 	return NopLogger
 }
 
+// AddCallerSkip returns a copy of the passed logger reporting the caller skip
+// additional stack frames above the log command. Libraries wrapping a logger
+// should use it so the file reported is the one calling the wrapper and not the
+// wrapper itself. If the logger does not support it, it is returned unchanged.
+func AddCallerSkip(l Logger, skip int) Logger {
+	if l, ok := l.(*logger); ok {
+		l2 := l.copy(0)
+		l2.callerSkip += skip
+		return l2
+	}
+	return l
+}
+
 // Copy returns a copy of the logger
 func (l *logger) Copy() Logger {
-	l2 := &logger{
-		level:          l.level,
-		output:         l.output,
-		fields:         map[string]interface{}{},
-		disablePooling: l.disablePooling,
+	return l.copy(0)
+}
+
+// With implements Logger interface
+func (l *logger) With(fields F) Logger {
+	l2 := l.copy(len(fields))
+	for k, v := range fields {
+		l2.fields[k] = v
 	}
+	return l2
+}
+
+// copy returns a copy of the logger with its own fields map, with room
+// for extra more fields.
+func (l *logger) copy(extra int) *logger {
+	l2 := &logger{}
+	*l2 = *l
+	l2.fields = make(map[string]interface{}, len(l.fields)+extra)
 	for k, v := range l.fields {
 		l2.fields[k] = v
 	}
@@ -181,37 +337,181 @@ func (l *logger) Copy() Logger {
 // close returns the logger to the pool for reuse
 func (l *logger) close() {
 	if !l.disablePooling {
-		l.level = 0
-		l.output = nil
-		l.fields = nil
+		*l = logger{}
 		loggerPool.Put(l)
 	}
 }
 
 func (l *logger) send(level Level, calldepth int, msg string, fields map[string]interface{}) {
-	if level < l.level || l.output == nil {
+	rate := l.sample(level, msg, fields)
+	if rate <= 0 {
 		return
 	}
-	data := make(map[string]interface{}, 4+len(fields)+len(l.fields))
+	l.write(level, calldepth+1, msg, fields, rate)
+}
+
+// sendf is like send but only formats the message once the level and sampling
+// checks passed. The format is used as the message template for sampling.
+func (l *logger) sendf(level Level, calldepth int, format string, v []interface{}, fields map[string]interface{}) {
+	rate := l.sample(level, format, fields)
+	if rate <= 0 {
+		return
+	}
+	l.write(level, calldepth+1, fmt.Sprintf(format, v...), fields, rate)
+}
+
+// sample checks the level of a message and returns the sampling rate to apply
+// to it. A rate of 0 means the message must be discarded.
+func (l *logger) sample(level Level, template string, fields map[string]interface{}) int {
+	if l.output == nil || !level.AtLeast(l.minLevel(fields)) {
+		return 0
+	}
+	if l.sampler == nil {
+		return 1
+	}
+	return l.sampler.Sample(level, template)
+}
+
+func (l *logger) write(level Level, calldepth int, msg string, fields map[string]interface{}, rate int) {
+	if l.counts != nil {
+		l.counts.inc(level)
+	}
+	data := make(map[string]interface{}, 6+len(fields)+len(l.fields))
 	data[KeyTime] = now()
 	data[KeyLevel] = level.String()
 	data[KeyMessage] = msg
-	if _, file, line, ok := runtime.Caller(calldepth); ok {
-		data[KeyFile] = path.Base(file) + ":" + strconv.FormatInt(int64(line), 10)
+	if rate > 1 {
+		data[KeySampleRate] = rate
+	}
+	if l.caller != CallerDisabled || l.callerFunc {
+		l.addCaller(data, calldepth+1)
+	}
+	if l.stackTraceLevel != nil && level.AtLeast(l.stackTraceLevel.Level()) {
+		data[KeyStack] = stackTrace(calldepth + l.callerSkip)
 	}
 	for k, v := range fields {
+		if lz, ok := v.(Lazy); ok {
+			v = lz()
+		}
 		data[k] = v
 	}
 	if l.fields != nil {
 		for k, v := range l.fields {
+			if lz, ok := v.(Lazy); ok {
+				v = lz()
+			}
 			data[k] = v
 		}
 	}
-	if err := l.output.Write(data); err != nil {
+	if l.buffer != nil {
+		buffered, flush := l.buffer.add(level, data)
+		if buffered {
+			return
+		}
+		for _, msg := range flush {
+			if err := l.output.Write(msg); err != nil && err != ErrBufferFull {
+				critialLogger.Print("send error: ", err.Error())
+			}
+		}
+	}
+	// Messages dropped by an OutputChannel are reported by the channel itself
+	if err := l.output.Write(data); err != nil && err != ErrBufferFull {
 		critialLogger.Print("send error: ", err.Error())
 	}
 }
 
+// addCaller adds the caller's file and function to data according to the
+// logger's caller configuration.
+func (l *logger) addCaller(data map[string]interface{}, calldepth int) {
+	pc, file, line, ok := runtime.Caller(calldepth + l.callerSkip)
+	if !ok {
+		return
+	}
+	var fn string
+	if l.caller == CallerFull || l.callerFunc {
+		if f := runtime.FuncForPC(pc); f != nil {
+			fn = f.Name()
+		}
+	}
+	switch l.caller {
+	case CallerShort:
+		data[KeyFile] = path.Base(file) + ":" + strconv.FormatInt(int64(line), 10)
+	case CallerFull:
+		data[KeyFile] = moduleFile(fn, file, mainModulePath, mainPackagePath) + ":" + strconv.FormatInt(int64(line), 10)
+	}
+	if l.callerFunc && fn != "" {
+		data[KeyFunc] = fn
+	}
+}
+
+// mainModulePath and mainPackagePath are the path of the main module and the
+// import path of the main package used to report CallerFull callers.
+var mainModulePath, mainPackagePath = mainModule()
+
+// moduleFile returns the path of file relative to the root of the main module
+// mod if the function fn belongs to it, instead of its absolute path on the
+// build machine. Files of other modules are prefixed by the import path of their
+// package. mainPkg is the import path of the main package, fn being reported in
+// package main.
+func moduleFile(fn, file, mod, mainPkg string) string {
+	base := path.Base(file)
+	// fn is like github.com/rs/xlog.(*logger).Info, dots in the last element of
+	// the import path being escaped as %2e.
+	i := strings.LastIndex(fn, "/") + 1
+	j := strings.Index(fn[i:], ".")
+	if j == -1 {
+		return base
+	}
+	pkg := strings.Replace(fn[:i+j], "%2e", ".", -1)
+	if pkg == "main" {
+		if mainPkg == "" {
+			return base
+		}
+		pkg = mainPkg
+	}
+	switch {
+	case mod == "":
+	case pkg == mod:
+		return base
+	case strings.HasPrefix(pkg, mod+"/"):
+		return pkg[len(mod)+1:] + "/" + base
+	}
+	return pkg + "/" + base
+}
+
+// minLevel returns the minimum level to output a message with the given fields,
+// taking component levels into account.
+func (l *logger) minLevel(fields map[string]interface{}) Level {
+	if len(l.componentLevels) > 0 {
+		// Logger fields take precedence over message fields like in send
+		c, ok := l.fields[KeyComponent].(string)
+		if !ok {
+			c, ok = fields[KeyComponent].(string)
+		}
+		if ok {
+			if lvl, found := componentLevel(l.componentLevels, c); found {
+				return lvl
+			}
+		}
+	}
+	return l.level.Level()
+}
+
+// componentLevel finds the level of the component c or of its closest parent
+// (i.e.: "db" for "db.pool").
+func componentLevel(levels map[string]Leveler, c string) (Level, bool) {
+	for {
+		if lvl, ok := levels[c]; ok && lvl != nil {
+			return lvl.Level(), true
+		}
+		i := strings.LastIndex(c, ".")
+		if i == -1 {
+			return 0, false
+		}
+		c = c[:i]
+	}
+}
+
 func extractFields(v *[]interface{}) map[string]interface{} {
 	if l := len(*v); l > 0 {
 		if f, ok := (*v)[l-1].(map[string]interface{}); ok {
@@ -244,6 +544,23 @@ func (l *logger) OutputF(level Level, calldepth int, msg string, fields map[stri
 	l.send(level, calldepth+1, msg, fields)
 }
 
+// Enabled implements Logger interface
+func (l *logger) Enabled(level Level) bool {
+	return l.output != nil && level.AtLeast(l.minLevel(nil))
+}
+
+// Trace implements Logger interface
+func (l *logger) Trace(v ...interface{}) {
+	f := extractFields(&v)
+	l.send(LevelTrace, 2, fmt.Sprint(v...), f)
+}
+
+// Tracef implements Logger interface
+func (l *logger) Tracef(format string, v ...interface{}) {
+	f := extractFields(&v)
+	l.sendf(LevelTrace, 2, format, v, f)
+}
+
 // Debug implements Logger interface
 func (l *logger) Debug(v ...interface{}) {
 	f := extractFields(&v)
@@ -253,7 +570,7 @@ func (l *logger) Debug(v ...interface{}) {
 // Debugf implements Logger interface
 func (l *logger) Debugf(format string, v ...interface{}) {
 	f := extractFields(&v)
-	l.send(LevelDebug, 2, fmt.Sprintf(format, v...), f)
+	l.sendf(LevelDebug, 2, format, v, f)
 }
 
 // Info implements Logger interface
@@ -265,7 +582,19 @@ func (l *logger) Info(v ...interface{}) {
 // Infof implements Logger interface
 func (l *logger) Infof(format string, v ...interface{}) {
 	f := extractFields(&v)
-	l.send(LevelInfo, 2, fmt.Sprintf(format, v...), f)
+	l.sendf(LevelInfo, 2, format, v, f)
+}
+
+// Notice implements Logger interface
+func (l *logger) Notice(v ...interface{}) {
+	f := extractFields(&v)
+	l.send(LevelNotice, 2, fmt.Sprint(v...), f)
+}
+
+// Noticef implements Logger interface
+func (l *logger) Noticef(format string, v ...interface{}) {
+	f := extractFields(&v)
+	l.sendf(LevelNotice, 2, format, v, f)
 }
 
 // Warn implements Logger interface
@@ -277,7 +606,7 @@ func (l *logger) Warn(v ...interface{}) {
 // Warnf implements Logger interface
 func (l *logger) Warnf(format string, v ...interface{}) {
 	f := extractFields(&v)
-	l.send(LevelWarn, 2, fmt.Sprintf(format, v...), f)
+	l.sendf(LevelWarn, 2, format, v, f)
 }
 
 // Error implements Logger interface
@@ -299,7 +628,29 @@ func (l *logger) Errorf(format string, v ...interface{}) {
 			format = format[0 : l-2]
 		}
 	}
-	l.send(LevelError, 2, fmt.Sprintf(format, v...), f)
+	l.sendf(LevelError, 2, format, v, f)
+}
+
+// Critical implements Logger interface
+func (l *logger) Critical(v ...interface{}) {
+	f := extractFields(&v)
+	l.send(LevelCritical, 2, fmt.Sprint(v...), f)
+}
+
+// Criticalf implements Logger interface
+//
+// Go vet users: you may append %v at the end of you format when using xlog.F{} as a last
+// argument to workaround go vet false alarm.
+func (l *logger) Criticalf(format string, v ...interface{}) {
+	f := extractFields(&v)
+	if f != nil {
+		// Let user add a %v at the end of the message when fields are passed to satisfy go vet
+		l := len(format)
+		if l > 2 && format[l-2] == '%' && format[l-1] == 'v' {
+			format = format[0 : l-2]
+		}
+	}
+	l.sendf(LevelCritical, 2, format, v, f)
 }
 
 // Fatal implements Logger interface
@@ -325,13 +676,45 @@ func (l *logger) Fatalf(format string, v ...interface{}) {
 			format = format[0 : l-2]
 		}
 	}
-	l.send(LevelFatal, 2, fmt.Sprintf(format, v...), f)
+	l.sendf(LevelFatal, 2, format, v, f)
 	if o, ok := l.output.(*OutputChannel); ok {
 		o.Close()
 	}
 	exit1()
 }
 
+// Panic implements Logger interface
+func (l *logger) Panic(v ...interface{}) {
+	f := extractFields(&v)
+	msg := fmt.Sprint(v...)
+	l.send(LevelPanic, 2, msg, f)
+	if o, ok := l.output.(*OutputChannel); ok {
+		o.Flush()
+	}
+	panic(msg)
+}
+
+// Panicf implements Logger interface
+//
+// Go vet users: you may append %v at the end of you format when using xlog.F{} as a last
+// argument to workaround go vet false alarm.
+func (l *logger) Panicf(format string, v ...interface{}) {
+	f := extractFields(&v)
+	if f != nil {
+		// Let user add a %v at the end of the message when fields are passed to satisfy go vet
+		l := len(format)
+		if l > 2 && format[l-2] == '%' && format[l-1] == 'v' {
+			format = format[0 : l-2]
+		}
+	}
+	msg := fmt.Sprintf(format, v...)
+	l.send(LevelPanic, 2, msg, f)
+	if o, ok := l.output.(*OutputChannel); ok {
+		o.Flush()
+	}
+	panic(msg)
+}
+
 // Write implements io.Writer interface
 func (l *logger) Write(p []byte) (int, error) {
 	msg := strings.TrimRight(string(p), "\n")
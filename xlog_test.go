@@ -104,6 +104,28 @@ func TestCopy(t *testing.T) {
 	assert.Equal(t, NopLogger, Copy(nil))
 }
 
+func TestWith(t *testing.T) {
+	oc := NewOutputChannel(newTestOutput())
+	defer oc.Close()
+	c := Config{
+		Level:  LevelError,
+		Output: oc,
+		Fields: F{"foo": "bar"},
+	}
+	l := New(c).(*logger)
+	l2 := l.With(F{"bar": "baz"}).(*logger)
+	assert.Equal(t, l.output, l2.output)
+	assert.Equal(t, l.level, l2.level)
+	assert.Equal(t, F{"foo": "bar"}, l.fields)
+	assert.Equal(t, F{"foo": "bar", "bar": "baz"}, l2.fields)
+	l2.SetField("baz", "qux")
+	assert.Equal(t, F{"foo": "bar"}, l.fields)
+	l.SetField("qux", "quux")
+	assert.Equal(t, F{"foo": "bar", "bar": "baz", "baz": "qux"}, l2.fields)
+
+	assert.Equal(t, NopLogger, NopLogger.With(F{"foo": "bar"}))
+}
+
 func TestNewDefautOutput(t *testing.T) {
 	L := New(Config{})
 	l, ok := L.(*logger)
@@ -129,12 +151,158 @@ func TestSend(t *testing.T) {
 	delete(last, "file")
 	assert.Equal(t, map[string]interface{}{"time": fakeNow, "level": "info", "message": "test", "foo": "bar", "bar": "baz"}, last)
 
-	l = New(Config{Output: o, Level: 1}).(*logger)
+	l = New(Config{Output: o, Level: LevelInfo}).(*logger)
 	o.reset()
 	l.send(0, 2, "test", F{"foo": "bar"})
 	assert.True(t, o.empty())
 }
 
+func TestSendLevelVar(t *testing.T) {
+	o := newTestOutput()
+	lv := NewLevelVar(LevelInfo)
+	c := Config{Output: o, Level: lv}
+	l := New(c).(*logger)
+	l2 := New(c).(*logger)
+	l.send(LevelDebug, 1, "test", nil)
+	assert.True(t, o.empty())
+	lv.SetLevel(LevelDebug)
+	l.send(LevelDebug, 1, "test", nil)
+	assert.Equal(t, "debug", o.get()["level"])
+	l2.send(LevelDebug, 1, "test", nil)
+	assert.Equal(t, "debug", o.get()["level"])
+	lv.SetLevel(LevelError)
+	l.With(F{"foo": "bar"}).Warn("test")
+	assert.True(t, o.empty())
+}
+
+func TestSendComponentLevels(t *testing.T) {
+	o := newTestOutput()
+	l := New(Config{
+		Output: o,
+		Level:  LevelInfo,
+		ComponentLevels: map[string]Leveler{
+			"db":       LevelWarn,
+			"db.cache": LevelDebug,
+		},
+	}).(*logger)
+	l.send(LevelInfo, 1, "test", F{"component": "db"})
+	assert.True(t, o.empty())
+	l.send(LevelInfo, 1, "test", F{"component": "db.pool"})
+	assert.True(t, o.empty())
+	l.send(LevelWarn, 1, "test", F{"component": "db.pool"})
+	assert.Equal(t, "db.pool", o.get()["component"])
+	l.send(LevelDebug, 1, "test", F{"component": "db.cache.lru"})
+	assert.Equal(t, "db.cache.lru", o.get()["component"])
+	l.send(LevelDebug, 1, "test", F{"component": "rpc"})
+	assert.True(t, o.empty())
+	l.send(LevelInfo, 1, "test", F{"component": "dbx"})
+	assert.Equal(t, "dbx", o.get()["component"])
+
+	l2 := l.With(F{"component": "db"})
+	l2.Info("test")
+	assert.True(t, o.empty())
+	l2.Warn("test")
+	assert.Equal(t, "db", o.get()["component"])
+}
+
+func TestSendCaller(t *testing.T) {
+	o := newTestOutput()
+	l := New(Config{Output: o, Caller: CallerDisabled}).(*logger)
+	l.Info("test")
+	last := o.get()
+	assert.NotContains(t, last, "file")
+	assert.NotContains(t, last, "func")
+
+	l = New(Config{Output: o, Caller: CallerFull}).(*logger)
+	l.Info("test")
+	last = o.get()
+	if mainModulePath == "github.com/rs/xlog" {
+		assert.Regexp(t, `^xlog_test\.go:\d+$`, last["file"])
+	} else {
+		assert.Regexp(t, `^github\.com/rs/xlog/xlog_test\.go:\d+$`, last["file"])
+	}
+	assert.NotContains(t, last, "func")
+
+	l = New(Config{Output: o, CallerFunc: true}).(*logger)
+	l.Info("test")
+	last = o.get()
+	assert.Regexp(t, `^xlog_test\.go:\d+$`, last["file"])
+	assert.Equal(t, "github.com/rs/xlog.TestSendCaller", last["func"])
+
+	l = New(Config{Output: o, Caller: CallerDisabled, CallerFunc: true}).(*logger)
+	l.Info("test")
+	last = o.get()
+	assert.NotContains(t, last, "file")
+	assert.Equal(t, "github.com/rs/xlog.TestSendCaller", last["func"])
+}
+
+func logWrapper(l Logger) {
+	AddCallerSkip(l, 1).Info("test")
+}
+
+func TestAddCallerSkip(t *testing.T) {
+	o := newTestOutput()
+	l := New(Config{Output: o, CallerFunc: true})
+	logWrapper(l)
+	last := o.get()
+	assert.Equal(t, "github.com/rs/xlog.TestAddCallerSkip", last["func"])
+	l.Info("test")
+	last = o.get()
+	assert.Equal(t, "github.com/rs/xlog.TestAddCallerSkip", last["func"])
+	assert.Equal(t, NopLogger, AddCallerSkip(NopLogger, 1))
+}
+
+func TestModuleFile(t *testing.T) {
+	const mod, mainPkg = "example.com/app", "example.com/app/cmd/server"
+	assert.Equal(t, "cmd/server/main.go", moduleFile("main.main", "/src/app/cmd/server/main.go", mod, mainPkg))
+	assert.Equal(t, "cmd/server/main.go", moduleFile("main.main.func1", "/src/app/cmd/server/main.go", mod, mainPkg))
+	assert.Equal(t, "app.go", moduleFile("example.com/app.Run", "/src/app/app.go", mod, mainPkg))
+	assert.Equal(t, "internal/db/db.go", moduleFile("example.com/app/internal/db.(*DB).Query", "/src/app/internal/db/db.go", mod, mainPkg))
+	assert.Equal(t, "github.com/rs/xlog/xlog.go", moduleFile("github.com/rs/xlog.(*logger).Info", "/go/pkg/mod/github.com/rs/xlog@v1.0.0/xlog.go", mod, mainPkg))
+	assert.Equal(t, "gopkg.in/yaml.v2/yaml.go", moduleFile("gopkg.in/yaml%2ev2.Unmarshal", "/go/yaml.v2/yaml.go", mod, mainPkg))
+	assert.Equal(t, "example.com/application/a.go", moduleFile("example.com/application.A", "/src/a.go", mod, mainPkg))
+
+	// Unknown main module and package
+	assert.Equal(t, "github.com/rs/xlog/xlog.go", moduleFile("github.com/rs/xlog.(*logger).Info", "/go/src/xlog/xlog.go", "", ""))
+	assert.Equal(t, "main.go", moduleFile("main.main", "/src/main.go", "", ""))
+	assert.Equal(t, "main.go", moduleFile("", "/src/main.go", mod, mainPkg))
+}
+
+func TestSendLazy(t *testing.T) {
+	o := newTestOutput()
+	l := New(Config{Output: o, Level: LevelInfo}).(*logger)
+	calls := 0
+	lazy := Lazy(func() interface{} {
+		calls++
+		return "computed"
+	})
+	l.Debug("test", F{"foo": lazy})
+	assert.True(t, o.empty())
+	assert.Equal(t, 0, calls)
+	l.Info("test", F{"foo": lazy})
+	assert.Equal(t, "computed", o.get()["foo"])
+	assert.Equal(t, 1, calls)
+	l.SetField("bar", lazy)
+	l.Info("test")
+	assert.Equal(t, "computed", o.get()["bar"])
+	assert.Equal(t, 2, calls)
+}
+
+func TestEnabled(t *testing.T) {
+	l := New(Config{
+		Output:          Discard,
+		Level:           LevelInfo,
+		ComponentLevels: map[string]Leveler{"db": LevelError},
+	}).(*logger)
+	assert.False(t, l.Enabled(LevelDebug))
+	assert.True(t, l.Enabled(LevelInfo))
+	assert.True(t, l.Enabled(LevelError))
+	db := l.With(F{"component": "db"})
+	assert.False(t, db.Enabled(LevelWarn))
+	assert.True(t, db.Enabled(LevelError))
+	assert.False(t, (&logger{}).Enabled(LevelPanic))
+}
+
 func TestSendDrop(t *testing.T) {
 	t.Skip()
 	r, w := io.Pipe()
@@ -190,6 +358,30 @@ func TestGetFields(t *testing.T) {
 	assert.Equal(t, F{"k": "v"}, l.GetFields())
 }
 
+func TestTrace(t *testing.T) {
+	o := newTestOutput()
+	l := New(Config{Output: o, Level: LevelTrace}).(*logger)
+	l.Trace("test", F{"foo": "bar"})
+	last := <-o.w
+	assert.Contains(t, last["file"], "log_test.go:")
+	delete(last, "file")
+	assert.Equal(t, map[string]interface{}{"time": fakeNow, "level": "trace", "message": "test", "foo": "bar"}, last)
+
+	l = New(Config{Output: o}).(*logger)
+	l.Trace("test")
+	assert.True(t, o.empty())
+}
+
+func TestTracef(t *testing.T) {
+	o := newTestOutput()
+	l := New(Config{Output: o, Level: LevelTrace}).(*logger)
+	l.Tracef("test %d", 1, F{"foo": "bar"})
+	last := <-o.w
+	assert.Contains(t, last["file"], "log_test.go:")
+	delete(last, "file")
+	assert.Equal(t, map[string]interface{}{"time": fakeNow, "level": "trace", "message": "test 1", "foo": "bar"}, last)
+}
+
 func TestDebug(t *testing.T) {
 	o := newTestOutput()
 	l := New(Config{Output: o}).(*logger)
@@ -230,6 +422,26 @@ func TestInfof(t *testing.T) {
 	assert.Equal(t, map[string]interface{}{"time": fakeNow, "level": "info", "message": "test 1", "foo": "bar"}, last)
 }
 
+func TestNotice(t *testing.T) {
+	o := newTestOutput()
+	l := New(Config{Output: o}).(*logger)
+	l.Notice("test", F{"foo": "bar"})
+	last := <-o.w
+	assert.Contains(t, last["file"], "log_test.go:")
+	delete(last, "file")
+	assert.Equal(t, map[string]interface{}{"time": fakeNow, "level": "notice", "message": "test", "foo": "bar"}, last)
+}
+
+func TestNoticef(t *testing.T) {
+	o := newTestOutput()
+	l := New(Config{Output: o}).(*logger)
+	l.Noticef("test %d", 1, F{"foo": "bar"})
+	last := <-o.w
+	assert.Contains(t, last["file"], "log_test.go:")
+	delete(last, "file")
+	assert.Equal(t, map[string]interface{}{"time": fakeNow, "level": "notice", "message": "test 1", "foo": "bar"}, last)
+}
+
 func TestWarn(t *testing.T) {
 	o := newTestOutput()
 	l := New(Config{Output: o}).(*logger)
@@ -270,6 +482,26 @@ func TestErrorf(t *testing.T) {
 	assert.Equal(t, map[string]interface{}{"time": fakeNow, "level": "error", "message": "test 1", "foo": "bar"}, last)
 }
 
+func TestCritical(t *testing.T) {
+	o := newTestOutput()
+	l := New(Config{Output: o}).(*logger)
+	l.Critical("test", F{"foo": "bar"})
+	last := <-o.w
+	assert.Contains(t, last["file"], "log_test.go:")
+	delete(last, "file")
+	assert.Equal(t, map[string]interface{}{"time": fakeNow, "level": "critical", "message": "test", "foo": "bar"}, last)
+}
+
+func TestCriticalf(t *testing.T) {
+	o := newTestOutput()
+	l := New(Config{Output: o}).(*logger)
+	l.Criticalf("test %d%v", 1, F{"foo": "bar"})
+	last := <-o.w
+	assert.Contains(t, last["file"], "log_test.go:")
+	delete(last, "file")
+	assert.Equal(t, map[string]interface{}{"time": fakeNow, "level": "critical", "message": "test 1", "foo": "bar"}, last)
+}
+
 func TestFatal(t *testing.T) {
 	e := exit1
 	exited := 0
@@ -300,6 +532,30 @@ func TestFatalf(t *testing.T) {
 	assert.Equal(t, 1, exited)
 }
 
+func TestPanic(t *testing.T) {
+	o := newTestOutput()
+	l := New(Config{Output: NewOutputChannel(o)}).(*logger)
+	assert.PanicsWithValue(t, "test", func() {
+		l.Panic("test", F{"foo": "bar"})
+	})
+	last := <-o.w
+	assert.Contains(t, last["file"], "log_test.go:")
+	delete(last, "file")
+	assert.Equal(t, map[string]interface{}{"time": fakeNow, "level": "panic", "message": "test", "foo": "bar"}, last)
+}
+
+func TestPanicf(t *testing.T) {
+	o := newTestOutput()
+	l := New(Config{Output: NewOutputChannel(o)}).(*logger)
+	assert.PanicsWithValue(t, "test 1", func() {
+		l.Panicf("test %d%v", 1, F{"foo": "bar"})
+	})
+	last := <-o.w
+	assert.Contains(t, last["file"], "log_test.go:")
+	delete(last, "file")
+	assert.Equal(t, map[string]interface{}{"time": fakeNow, "level": "panic", "message": "test 1", "foo": "bar"}, last)
+}
+
 func TestWrite(t *testing.T) {
 	o := newTestOutput()
 	xl := New(Config{Output: NewOutputChannel(o)}).(*logger)